@@ -0,0 +1,441 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variable names understood by Load.
+const (
+	ENV_CONFIG_FILE        = "CONFIG_FILE"
+	ENV_HTTP_ADDR          = "HTTP_ADDR"
+	ENV_READ_TIMEOUT       = "HTTP_READ_TIMEOUT"
+	ENV_WRITE_TIMEOUT      = "HTTP_WRITE_TIMEOUT"
+	ENV_IDLE_TIMEOUT       = "HTTP_IDLE_TIMEOUT"
+	ENV_SHUTDOWN_TIMEOUT   = "SHUTDOWN_TIMEOUT"
+	ENV_TLS_CERT_FILE      = "TLS_CERT_FILE"
+	ENV_TLS_KEY_FILE       = "TLS_KEY_FILE"
+	ENV_DB_PATH            = "DB_PATH"
+	ENV_SECRET_KEY         = "SECRET_KEY"
+	ENV_ADMIN_USER_IDS     = "ADMIN_USER_IDS"
+	ENV_WEB_TOKEN_TTL      = "WEB_TOKEN_TTL"
+	ENV_DESKTOP_TOKEN_TTL  = "DESKTOP_TOKEN_TTL"
+	ENV_ALLOW_ORIGINS      = "ALLOW_ORIGINS"
+	ENV_LIVEKIT_URL        = "LIVEKIT_URL"
+	ENV_LIVEKIT_API_KEY    = "LIVEKIT_API_KEY"
+	ENV_LIVEKIT_SECRET     = "LIVEKIT_API_SECRET"
+	ENV_VOICE_PRESENCE_TTL = "VOICE_PRESENCE_TTL"
+	ENV_VOICE_REAP_EVERY   = "VOICE_REAP_INTERVAL"
+	ENV_VOICE_TOKEN_TTL    = "VOICE_TOKEN_TTL"
+	ENV_FRIEND_COOLDOWN    = "FRIEND_REQUEST_COOLDOWN"
+	ENV_FRIEND_REQUEST_TTL = "FRIEND_REQUEST_TTL"
+	ENV_FRIEND_REAP_EVERY  = "FRIEND_REQUEST_EXPIRE_INTERVAL"
+	ENV_LOG_LEVEL          = "LOG_LEVEL"
+	ENV_LOG_FORMAT         = "LOG_FORMAT"
+)
+
+const redacted = "<redacted>"
+
+// Current is the configuration loaded at startup.
+var Current = Default()
+
+// Duration is a time.Duration that reads and writes as a Go duration string ("24h", "30s").
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText formats the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// ServerConfig holds HTTP listener settings.
+type ServerConfig struct {
+	Addr            string   `yaml:"addr" toml:"addr"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	TLSCertFile     string   `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile      string   `yaml:"tls_key_file" toml:"tls_key_file"`
+}
+
+// TLSEnabled reports whether the server should listen with TLS.
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// DatabaseConfig holds storage settings.
+type DatabaseConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+// AuthConfig holds JWT signing settings.
+type AuthConfig struct {
+	SecretKey       string   `yaml:"secret_key" toml:"secret_key"`
+	WebTokenTTL     Duration `yaml:"web_token_ttl" toml:"web_token_ttl"`
+	DesktopTokenTTL Duration `yaml:"desktop_token_ttl" toml:"desktop_token_ttl"`
+	AdminUserIDs    []string `yaml:"admin_user_ids" toml:"admin_user_ids"` // UUIDs of server administrators
+}
+
+// IsAdmin reports whether the user UUID belongs to a server administrator.
+func (a AuthConfig) IsAdmin(userID string) bool {
+	for _, adminID := range a.AdminUserIDs {
+		if adminID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSConfig holds cross-origin settings.
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins" toml:"allow_origins"`
+}
+
+// LiveKitConfig holds LiveKit credentials used to mint room tokens.
+type LiveKitConfig struct {
+	URL       string `yaml:"url" toml:"url"`
+	APIKey    string `yaml:"api_key" toml:"api_key"`
+	APISecret string `yaml:"api_secret" toml:"api_secret"`
+}
+
+// VoiceConfig holds room voice presence settings.
+type VoiceConfig struct {
+	PresenceTTL  Duration `yaml:"presence_ttl" toml:"presence_ttl"`   // drop participants without a heartbeat for this long
+	ReapInterval Duration `yaml:"reap_interval" toml:"reap_interval"` // how often stale presence is checked
+	TokenTTL     Duration `yaml:"token_ttl" toml:"token_ttl"`         // lifetime of LiveKit access tokens
+}
+
+// FriendsConfig holds friend request settings.
+type FriendsConfig struct {
+	RequestCooldown Duration `yaml:"request_cooldown" toml:"request_cooldown"` // wait after a decline before asking the same user again; 0 disables
+	RequestTTL      Duration `yaml:"request_ttl" toml:"request_ttl"`           // pending requests older than this expire
+	ExpireInterval  Duration `yaml:"expire_interval" toml:"expire_interval"`   // how often stale requests are expired
+}
+
+// LogConfig holds structured logging settings.
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`   // debug, info, warn, error
+	Format string `yaml:"format" toml:"format"` // text, json
+}
+
+// Config is the typed application configuration.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	CORS     CORSConfig     `yaml:"cors" toml:"cors"`
+	LiveKit  LiveKitConfig  `yaml:"livekit" toml:"livekit"`
+	Voice    VoiceConfig    `yaml:"voice" toml:"voice"`
+	Friends  FriendsConfig  `yaml:"friends" toml:"friends"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     Duration{15 * time.Second},
+			WriteTimeout:    Duration{30 * time.Second},
+			IdleTimeout:     Duration{120 * time.Second},
+			ShutdownTimeout: Duration{20 * time.Second},
+		},
+		Database: DatabaseConfig{
+			Path: "./data/gocall.db",
+		},
+		Auth: AuthConfig{
+			WebTokenTTL:     Duration{24 * time.Hour},
+			DesktopTokenTTL: Duration{30 * 24 * time.Hour},
+		},
+		Voice: VoiceConfig{
+			PresenceTTL:  Duration{90 * time.Second},
+			ReapInterval: Duration{30 * time.Second},
+			TokenTTL:     Duration{time.Hour},
+		},
+		Friends: FriendsConfig{
+			RequestCooldown: Duration{24 * time.Hour},
+			RequestTTL:      Duration{30 * 24 * time.Hour},
+			ExpireInterval:  Duration{time.Hour},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// LiveKitConfigured reports whether LiveKit API credentials are set.
+func (c *Config) LiveKitConfigured() bool {
+	return c.LiveKit.APIKey != "" && c.LiveKit.APISecret != ""
+}
+
+// Load builds the configuration from defaults, then a YAML/TOML file, then
+// environment variables and finally command line flags, each layer overriding
+// the previous one. The result is validated before it is returned.
+func Load(args []string) (*Config, error) {
+	godotenv.Load()
+
+	cfg := Default()
+
+	fs := flag.NewFlagSet("gocall", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(ENV_CONFIG_FILE), "path to a YAML or TOML config file")
+	addr := fs.String("addr", "", "HTTP listen address")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	dbPath := fs.String("db", "", "SQLite database path")
+	allowOrigins := fs.String("allow-origins", "", "comma separated list of allowed CORS origins")
+	livekitURL := fs.String("livekit-url", "", "public LiveKit URL handed to clients")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn, error")
+	logFormat := fs.String("log-format", "", "log format: text, json")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "tls-cert":
+			cfg.Server.TLSCertFile = *tlsCert
+		case "tls-key":
+			cfg.Server.TLSKeyFile = *tlsKey
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "allow-origins":
+			cfg.CORS.AllowOrigins = splitList(*allowOrigins)
+		case "livekit-url":
+			cfg.LiveKit.URL = *livekitURL
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	setString := func(name string, dst *string) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*dst = value
+		}
+	}
+	setDuration := func(name string, dst *Duration) error {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return nil
+		}
+		if err := dst.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	setString(ENV_HTTP_ADDR, &cfg.Server.Addr)
+	setString(ENV_TLS_CERT_FILE, &cfg.Server.TLSCertFile)
+	setString(ENV_TLS_KEY_FILE, &cfg.Server.TLSKeyFile)
+	setString(ENV_DB_PATH, &cfg.Database.Path)
+	setString(ENV_SECRET_KEY, &cfg.Auth.SecretKey)
+	setString(ENV_LIVEKIT_URL, &cfg.LiveKit.URL)
+	setString(ENV_LIVEKIT_API_KEY, &cfg.LiveKit.APIKey)
+	setString(ENV_LIVEKIT_SECRET, &cfg.LiveKit.APISecret)
+	setString(ENV_LOG_LEVEL, &cfg.Log.Level)
+	setString(ENV_LOG_FORMAT, &cfg.Log.Format)
+	if value := os.Getenv(ENV_ALLOW_ORIGINS); value != "" {
+		cfg.CORS.AllowOrigins = splitList(value)
+	}
+	if value := os.Getenv(ENV_ADMIN_USER_IDS); value != "" {
+		cfg.Auth.AdminUserIDs = splitList(value)
+	}
+
+	durations := map[string]*Duration{
+		ENV_READ_TIMEOUT:       &cfg.Server.ReadTimeout,
+		ENV_WRITE_TIMEOUT:      &cfg.Server.WriteTimeout,
+		ENV_IDLE_TIMEOUT:       &cfg.Server.IdleTimeout,
+		ENV_SHUTDOWN_TIMEOUT:   &cfg.Server.ShutdownTimeout,
+		ENV_WEB_TOKEN_TTL:      &cfg.Auth.WebTokenTTL,
+		ENV_DESKTOP_TOKEN_TTL:  &cfg.Auth.DesktopTokenTTL,
+		ENV_VOICE_PRESENCE_TTL: &cfg.Voice.PresenceTTL,
+		ENV_VOICE_REAP_EVERY:   &cfg.Voice.ReapInterval,
+		ENV_VOICE_TOKEN_TTL:    &cfg.Voice.TokenTTL,
+		ENV_FRIEND_COOLDOWN:    &cfg.Friends.RequestCooldown,
+		ENV_FRIEND_REQUEST_TTL: &cfg.Friends.RequestTTL,
+		ENV_FRIEND_REAP_EVERY:  &cfg.Friends.ExpireInterval,
+	}
+	for name, dst := range durations {
+		if err := setDuration(name, dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty (set HTTP_ADDR or -addr)"))
+	}
+	if c.Server.ReadTimeout.Duration < 0 || c.Server.WriteTimeout.Duration < 0 || c.Server.IdleTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server timeouts must not be negative"))
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+	}
+	for _, file := range []string{c.Server.TLSCertFile, c.Server.TLSKeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("tls file %s: %w", file, err))
+		}
+	}
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path must not be empty (set DB_PATH or -db)"))
+	}
+	if c.Auth.SecretKey == "" {
+		errs = append(errs, errors.New("auth.secret_key is required (set SECRET_KEY)"))
+	}
+	if c.Auth.WebTokenTTL.Duration <= 0 {
+		errs = append(errs, errors.New("auth.web_token_ttl must be positive"))
+	}
+	if c.Auth.DesktopTokenTTL.Duration <= 0 {
+		errs = append(errs, errors.New("auth.desktop_token_ttl must be positive"))
+	}
+	if len(c.CORS.AllowOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allow_origins is required (set ALLOW_ORIGINS or -allow-origins)"))
+	}
+	if c.Voice.PresenceTTL.Duration <= 0 {
+		errs = append(errs, errors.New("voice.presence_ttl must be positive"))
+	}
+	if c.Voice.ReapInterval.Duration <= 0 {
+		errs = append(errs, errors.New("voice.reap_interval must be positive"))
+	}
+	if c.Voice.TokenTTL.Duration <= 0 {
+		errs = append(errs, errors.New("voice.token_ttl must be positive"))
+	}
+	if c.Friends.RequestCooldown.Duration < 0 {
+		errs = append(errs, errors.New("friends.request_cooldown must not be negative"))
+	}
+	if c.Friends.RequestTTL.Duration <= 0 {
+		errs = append(errs, errors.New("friends.request_ttl must be positive"))
+	}
+	if c.Friends.ExpireInterval.Duration <= 0 {
+		errs = append(errs, errors.New("friends.expire_interval must be positive"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be one of debug, info, warn, error", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format %q must be text or json", c.Log.Format))
+	}
+	if (c.LiveKit.APIKey == "") != (c.LiveKit.APISecret == "") {
+		errs = append(errs, errors.New("livekit.api_key and livekit.api_secret must be set together"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.CORS.AllowOrigins = append([]string(nil), c.CORS.AllowOrigins...)
+	copied.Auth.AdminUserIDs = append([]string(nil), c.Auth.AdminUserIDs...)
+	if copied.Auth.SecretKey != "" {
+		copied.Auth.SecretKey = redacted
+	}
+	if copied.LiveKit.APISecret != "" {
+		copied.LiveKit.APISecret = redacted
+	}
+	return &copied
+}
+
+// Print writes the configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
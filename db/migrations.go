@@ -0,0 +1,91 @@
+package db
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Room kinds.
+const (
+	RoomKindGroup  = "group"
+	RoomKindDirect = "direct"
+)
+
+// legacyDirectRoomPrefix is how direct rooms were encoded in Room.Name
+// before Room.Kind existed: "__direct__:" + the two sorted user UUIDs.
+const legacyDirectRoomPrefix = "__direct__:"
+
+// DirectRoomKey returns the unique key of the direct room between two users.
+func DirectRoomKey(userA, userB string) string {
+	pair := []string{userA, userB}
+	sort.Strings(pair)
+	return strings.Join(pair, ":")
+}
+
+// migrateLegacyDirectRooms converts name-encoded direct rooms into kind=direct rooms.
+// A second room for an already migrated pair is merged into the first one.
+func migrateLegacyDirectRooms(gdb *gorm.DB) error {
+	var rooms []Room
+	if err := gdb.Where("substr(name, 1, ?) = ? AND kind <> ?", len(legacyDirectRoomPrefix), legacyDirectRoomPrefix, RoomKindDirect).
+		Order("id ASC").
+		Find(&rooms).Error; err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		pair := strings.Split(strings.TrimPrefix(room.Name, legacyDirectRoomPrefix), ":")
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			slog.Warn("skipping malformed direct room", "room_id", room.RoomID)
+			continue
+		}
+		key := DirectRoomKey(pair[0], pair[1])
+
+		var survivor Room
+		if err := gdb.Where("direct_key = ?", key).Limit(1).Find(&survivor).Error; err != nil {
+			return err
+		}
+		if survivor.ID != 0 {
+			if err := gdb.Transaction(func(tx *gorm.DB) error {
+				return mergeDirectRoom(tx, &room, &survivor)
+			}); err != nil {
+				return err
+			}
+			slog.Info("merged duplicate direct room", "room_id", room.RoomID, "into", survivor.RoomID)
+			continue
+		}
+
+		if err := gdb.Model(&Room{}).Where("id = ?", room.ID).Updates(map[string]interface{}{
+			"kind":       RoomKindDirect,
+			"direct_key": key,
+			"name":       "",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDirectRoom moves the members and invites of duplicate into survivor and deletes duplicate.
+func mergeDirectRoom(tx *gorm.DB, duplicate, survivor *Room) error {
+	if err := tx.Model(&RoomMember{}).
+		Where("room_id = ? AND user_id NOT IN (?)", duplicate.RoomID, tx.Model(&RoomMember{}).Select("user_id").Where("room_id = ?", survivor.RoomID)).
+		Update("room_id", survivor.RoomID).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&RoomInvite{}, &Notification{}} {
+		if err := tx.Model(model).Where("room_id = ?", duplicate.RoomID).Update("room_id", survivor.RoomID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Остальное (голос, коды, баны) для личных комнат не переносим
+	for _, model := range []interface{}{&RoomMember{}, &RoomInviteCode{}, &RoomVoiceParticipant{}, &RoomBan{}} {
+		if err := tx.Where("room_id = ?", duplicate.RoomID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(duplicate).Error
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gdb, err := gorm.Open(openSQLite("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := gdb.AutoMigrate(models()...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return gdb
+}
+
+// createLegacyRoom stores a name-encoded direct room and returns its RoomID.
+func createLegacyRoom(t *testing.T, gdb *gorm.DB, userA, userB string, members ...string) string {
+	t.Helper()
+	room := Room{UserID: userA, Name: legacyDirectRoomPrefix + userA + ":" + userB, Type: "private"}
+	if err := gdb.Create(&room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	for _, member := range members {
+		if err := gdb.Create(&RoomMember{RoomID: room.RoomID, UserID: member, Role: "member"}).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+	return room.RoomID
+}
+
+func TestMigrateLegacyDirectRoomsMergesDuplicates(t *testing.T) {
+	gdb := openTestDB(t)
+	first := createLegacyRoom(t, gdb, "a", "b", "a")
+	second := createLegacyRoom(t, gdb, "b", "a", "a", "b")
+	if err := gdb.Create(&RoomInvite{RoomID: second, InviterUserID: "a", InvitedUserID: "b"}).Error; err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+
+	if err := migrateLegacyDirectRooms(gdb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var rooms []Room
+	if err := gdb.Find(&rooms).Error; err != nil {
+		t.Fatalf("fetch rooms: %v", err)
+	}
+	if len(rooms) != 1 {
+		t.Fatalf("%d rooms left, want 1", len(rooms))
+	}
+	room := rooms[0]
+	if room.RoomID != first || room.Kind != RoomKindDirect || room.Name != "" || room.DirectKey == nil || *room.DirectKey != "a:b" {
+		t.Fatalf("room = %+v, want the first room migrated to direct", room)
+	}
+
+	var members []string
+	if err := gdb.Model(&RoomMember{}).Where("room_id = ?", first).Order("user_id").Pluck("user_id", &members).Error; err != nil {
+		t.Fatalf("fetch members: %v", err)
+	}
+	if len(members) != 2 || members[0] != "a" || members[1] != "b" {
+		t.Fatalf("members = %v, want [a b]", members)
+	}
+
+	var invites, orphans int64
+	gdb.Model(&RoomInvite{}).Where("room_id = ?", first).Count(&invites)
+	gdb.Model(&RoomMember{}).Where("room_id = ?", second).Count(&orphans)
+	if invites != 1 || orphans != 0 {
+		t.Fatalf("invites moved = %d, members left in duplicate = %d, want 1 and 0", invites, orphans)
+	}
+}
@@ -1,11 +1,12 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -26,50 +27,140 @@ type User struct {
 
 // Friend represents a friendship between two users
 type Friend struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    string    `gorm:"not null" json:"user_id"`
-	FriendID  string    `gorm:"not null" json:"friend_id"`
-	IsPinned  bool      `gorm:"default:false" json:"is_pinned"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      string    `gorm:"not null" json:"user_id"`
+	FriendID    string    `gorm:"not null" json:"friend_id"`
+	IsPinned    bool      `gorm:"default:false" json:"is_pinned"`
+	PinPosition int       `gorm:"not null;default:0" json:"pin_position"` // order among pinned friends
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // FriendRequest stores a pending or resolved friend request.
 type FriendRequest struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	FromUserID string    `gorm:"not null" json:"from_user_id"`
-	ToUserID   string    `gorm:"not null" json:"to_user_id"`
-	Status     string    `gorm:"default:'pending';not null" json:"status"` // pending, accepted, declined
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	FromUserID  string     `gorm:"not null" json:"from_user_id"`
+	ToUserID    string     `gorm:"not null" json:"to_user_id"`
+	Status      string     `gorm:"default:'pending';not null" json:"status"` // pending, accepted, declined
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"` // when the request was accepted or declined
 }
 
-// Room represents a room
-type Room struct {
+// FriendGroup is a user-defined list of friends, ordered by Position.
+type FriendGroup struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	RoomID    string    `gorm:"unique;not null" json:"room_id"` // UUID
-	UserID    string    `gorm:"not null" json:"user_id"`        // Creator's user UUID
-	Name      string    `gorm:"not null" json:"name"`
-	Type      string    `gorm:"not null" json:"type"`      // public, private, secret
-	Password  string    `gorm:"type:text" json:"password"` // null if not password-protected
+	OwnerID   string    `gorm:"not null;uniqueIndex:idx_friend_group_name" json:"-"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_friend_group_name" json:"name"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// FriendGroupMember places a friend (by UUID) in a friend group.
+type FriendGroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	GroupID   uint      `gorm:"not null;uniqueIndex:idx_friend_group_member" json:"group_id"`
+	FriendID  string    `gorm:"not null;uniqueIndex:idx_friend_group_member;index" json:"friend_id"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
+// UserBlock records that BlockerID has blocked BlockedID. Blocks are one-way;
+// either direction stops friendship, requests, invites and chat between the pair.
+type UserBlock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BlockerID string    `gorm:"not null;uniqueIndex:idx_user_block_pair" json:"blocker_id"`
+	BlockedID string    `gorm:"not null;uniqueIndex:idx_user_block_pair;index" json:"blocked_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// UserPrivacy holds a user's privacy preferences. Users without a row get the defaults.
+type UserPrivacy struct {
+	ID                  uint      `gorm:"primaryKey" json:"-"`
+	UserID              string    `gorm:"uniqueIndex;not null" json:"-"`
+	AllowDirectAdd      bool      `gorm:"default:false" json:"allow_direct_add"`              // others may skip the friend request flow
+	HideFromSuggestions bool      `gorm:"default:false" json:"hide_from_suggestions"`         // never listed in "people you may know"
+	HideMutualFriends   bool      `gorm:"default:false" json:"hide_mutual_friends"`           // others may not list friends in common
+	AllowEmailLookup    bool      `gorm:"default:false" json:"allow_email_lookup"`            // user search may match the exact e-mail address
+	Discoverability     string    `gorm:"not null;default:'everyone'" json:"discoverability"` // who can find the user: everyone, friends_of_friends, nobody
+	FriendRequests      string    `gorm:"not null;default:'everyone'" json:"friend_requests"` // who can send friend requests: everyone, friends_of_friends, nobody
+	RoomInvites         string    `gorm:"not null;default:'everyone'" json:"room_invites"`    // who can invite to rooms: everyone, friends, nobody
+	OnlineStatus        string    `gorm:"not null;default:'everyone'" json:"online_status"`   // who sees is_online: everyone, friends, nobody
+	UpdatedAt           time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Notification is an entry in a user's notification center.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"not null;index:idx_notification_user" json:"-"` // recipient
+	Type      string     `gorm:"not null" json:"type"`                          // friend_request, friend_accepted, room_invite, room_role_changed, message
+	ActorID   string     `json:"actor_id,omitempty"`                            // user who caused it
+	RoomID    string     `json:"room_id,omitempty"`
+	RefID     uint       `json:"ref_id,omitempty"`  // friend request or room invite ID
+	Role      string     `json:"role,omitempty"`    // new role for room_role_changed
+	Preview   string     `json:"preview,omitempty"` // latest message text for message
+	Count     int        `gorm:"default:1" json:"count"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Room represents a room
+type Room struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	RoomID           string    `gorm:"unique;not null" json:"room_id"` // UUID
+	UserID           string    `gorm:"not null" json:"user_id"`        // Creator's user UUID
+	Name             string    `gorm:"not null" json:"name"`
+	Type             string    `gorm:"not null" json:"type"`                       // public, private, secret
+	Password         string    `gorm:"type:text" json:"-"`                         // empty if not password-protected; never serialized
+	HasPassword      bool      `gorm:"-" json:"has_password"`                      // set by the AfterFind/AfterSave hooks
+	Kind             string    `gorm:"not null;default:'group';index" json:"kind"` // group, direct
+	DirectKey        *string   `gorm:"uniqueIndex" json:"-"`                       // sorted "userA:userB" pair, direct rooms only
+	Description      string    `gorm:"type:text" json:"description"`
+	Tags             string    `gorm:"type:text" json:"tags"`                               // comma-separated, lowercase
+	VoicePublishRole string    `gorm:"not null;default:'member'" json:"voice_publish_role"` // minimum role allowed to publish mic/camera
+	ScreenShareRole  string    `gorm:"not null;default:'member'" json:"screen_share_role"`  // minimum role allowed to share screen
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
 // RoomMember represents a member in a room
 type RoomMember struct {
 	ID       uint      `gorm:"primaryKey" json:"id"`
 	RoomID   string    `gorm:"not null;index:idx_room_member_user,unique" json:"room_id"` // Room's UUID
 	UserID   string    `gorm:"not null;index:idx_room_member_user,unique" json:"user_id"` // User's UUID
-	Role     string    `gorm:"not null" json:"role"`                                      // Role in the room (admin, member, viewer)
+	Role     string    `gorm:"not null" json:"role"`                                      // Role in the room (creator, admin, member, viewer)
 	JoinedAt time.Time `gorm:"autoCreateTime" json:"joined_at"`
 }
 
 // RoomInvite stores a pending or resolved invitation to a room.
 type RoomInvite struct {
-	ID            uint      `gorm:"primaryKey" json:"id"`
-	RoomID        string    `gorm:"not null" json:"room_id"`
-	InviterUserID string    `gorm:"not null" json:"inviter_user_id"`
-	InvitedUserID string    `gorm:"not null" json:"invited_user_id"`
-	Status        string    `gorm:"default:'pending';not null" json:"status"`
-	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	RoomID        string     `gorm:"not null" json:"room_id"`
+	InviterUserID string     `gorm:"not null" json:"inviter_user_id"`
+	InvitedUserID string     `gorm:"not null" json:"invited_user_id"`
+	Status        string     `gorm:"default:'pending';not null" json:"status"`
+	ExpiresAt     *time.Time `json:"expires_at"` // nil means never
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RoomInviteCode is a shareable link-style invite that any authenticated user can redeem.
+type RoomInviteCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	RoomID    string     `gorm:"not null;index" json:"room_id"`
+	Code      string     `gorm:"unique;not null" json:"code"`
+	CreatedBy string     `gorm:"not null" json:"created_by"`
+	Role      string     `gorm:"not null;default:'member'" json:"role"` // role granted on redemption
+	MaxUses   int        `gorm:"not null;default:0" json:"max_uses"`    // 0 means unlimited
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"` // nil means never
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RoomBan keeps a user out of a room until they are unbanned.
+type RoomBan struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RoomID    string    `gorm:"not null;index:idx_room_ban_user,unique" json:"room_id"`
+	UserID    string    `gorm:"not null;index:idx_room_ban_user,unique" json:"user_id"`
+	BannedBy  string    `gorm:"not null" json:"banned_by"`
+	Reason    string    `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // RoomVoiceParticipant represents explicit voice presence inside a room.
@@ -94,28 +185,85 @@ type Message struct {
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
-// InitDatabase initializes the SQLite database using GORM
-func InitDatabase(path string) {
-	var err error
-	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-
-	// Auto-migrate all models
-	err = DB.AutoMigrate(
+// models lists every table managed by AutoMigrate.
+func models() []interface{} {
+	return []interface{}{
 		&User{},
 		&Friend{},
 		&FriendRequest{},
+		&FriendGroup{},
+		&FriendGroupMember{},
+		&UserBlock{},
+		&UserPrivacy{},
 		&Room{},
 		&RoomMember{},
 		&RoomInvite{},
+		&RoomInviteCode{},
+		&RoomBan{},
 		&RoomVoiceParticipant{},
 		&Message{},
-	)
+		&Notification{},
+	}
+}
+
+// InitDatabase initializes the SQLite database using GORM
+func InitDatabase(path string) {
+	var err error
+	DB, err = gorm.Open(openSQLite(path), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Auto-migrate all models
+	err = DB.AutoMigrate(models()...)
 	if err != nil {
 		log.Fatal("Failed to migrate database schema:", err)
 	}
+
+	if err := migrateLegacyDirectRooms(DB); err != nil {
+		log.Fatal("Failed to migrate direct rooms:", err)
+	}
+}
+
+// Ping verifies the database connection is usable.
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckMigrations reports the first table or column of a model missing from the schema.
+func CheckMigrations() error {
+	migrator := DB.Migrator()
+	for _, model := range models() {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table %s is missing", stmt.Schema.Table)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !migrator.HasColumn(model, field.DBName) {
+				return fmt.Errorf("column %s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying database connection pool.
+func Close() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 // BeforeCreate assigns a UUID before a room is persisted.
@@ -124,6 +272,18 @@ func (r *Room) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// AfterFind derives HasPassword once a room is loaded.
+func (r *Room) AfterFind(tx *gorm.DB) (err error) {
+	r.HasPassword = r.Password != ""
+	return
+}
+
+// AfterSave keeps HasPassword in sync after a room is created or updated.
+func (r *Room) AfterSave(tx *gorm.DB) (err error) {
+	r.HasPassword = r.Password != ""
+	return
+}
+
 // BeforeCreate assigns a UUID before a user is persisted.
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	u.UserID = uuid.New().String()
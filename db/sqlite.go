@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDriverName is the go-sqlite3 driver with a Unicode-aware lower().
+const sqliteDriverName = "sqlite3_unicode"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// Встроенная lower() понимает только ASCII: "Иван" не находится по "иван"
+			return conn.RegisterFunc("lower", unicodeLower, true)
+		},
+	})
+}
+
+// unicodeLower lowercases text with strings.ToLower and passes other values, NULL included, through.
+func unicodeLower(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(v)
+	case []byte:
+		if v == nil {
+			return nil
+		}
+	}
+	return value
+}
+
+// openSQLite returns a dialector for the database at path using sqliteDriverName.
+func openSQLite(path string) gorm.Dialector {
+	return sqlite.New(sqlite.Config{DriverName: sqliteDriverName, DSN: path})
+}
@@ -0,0 +1,20 @@
+package db
+
+import "testing"
+
+func TestUnicodeLower(t *testing.T) {
+	gdb := openTestDB(t)
+
+	var row struct {
+		Lowered string
+		IsNull  bool
+		Matches bool
+	}
+	if err := gdb.Raw("SELECT lower(?) AS lowered, lower(NULL) IS NULL AS is_null, lower(?) LIKE ? AS matches", "ИВАН Petrov", "Иван", "ив%").
+		Scan(&row).Error; err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if row.Lowered != "иван petrov" || !row.IsNull || !row.Matches {
+		t.Fatalf("got %+v, want lowered Cyrillic, NULL kept and a prefix match", row)
+	}
+}
@@ -78,6 +78,6 @@ func Login(c *gin.Context) {
 	}
 
 	// Generate JWT
-	token, _ := utils.GenerateJWT(int(user.ID))
+	token, _ := utils.GenerateJWT(int(user.ID), user.UserID)
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// blockedUser is an entry of the caller's block list.
+type blockedUser struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// isBlockedBetween reports whether either user has blocked the other.
+func isBlockedBetween(userA, userB string) (bool, error) {
+	var count int64
+	if err := db.DB.Model(&db.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userA, userB, userB, userA).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// loadUserByUsername fetches the target of a block request, answering 404 when missing.
+func loadUserByUsername(c *gin.Context, username string) (*db.User, bool) {
+	var user db.User
+	if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User with this username not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return nil, false
+	}
+	return &user, true
+}
+
+// BlockUser blocks another user. Any friendship, pending friend requests and
+// pending room invites between the two users are removed.
+func BlockUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'username' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	target, ok := loadUserByUsername(c, req.Username)
+	if !ok {
+		return
+	}
+	if target.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+
+	block := db.UserBlock{BlockerID: currentUser.UserID, BlockedID: target.UserID}
+	var alreadyBlocked bool
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&db.UserBlock{}).
+			Where("blocker_id = ? AND blocked_id = ?", currentUser.UserID, target.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			alreadyBlocked = true
+			return nil
+		}
+		if err := tx.Create(&block).Error; err != nil {
+			return err
+		}
+
+		// Блокировка разрывает все связи между пользователями
+		pair := []interface{}{currentUser.UserID, target.UserID, target.UserID, currentUser.UserID}
+		if err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", pair...).
+			Delete(&db.Friend{}).Error; err != nil {
+			return err
+		}
+		if err := removeFromFriendGroups(tx, currentUser.UserID, target.UserID); err != nil {
+			return err
+		}
+		if err := tx.Where("status = 'pending' AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))", pair...).
+			Delete(&db.FriendRequest{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("status = 'pending' AND ((inviter_user_id = ? AND invited_user_id = ?) OR (inviter_user_id = ? AND invited_user_id = ?))", pair...).
+			Delete(&db.RoomInvite{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+	if alreadyBlocked {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already blocked"})
+		return
+	}
+
+	utils.Log(c).Info("blocked user", "target", target.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked", "user_id": target.UserID})
+}
+
+// UnblockUser lifts a block. The friendship is not restored.
+func UnblockUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'username' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	target, ok := loadUserByUsername(c, req.Username)
+	if !ok {
+		return
+	}
+
+	result := db.DB.Where("blocker_id = ? AND blocked_id = ?", currentUser.UserID, target.UserID).Delete(&db.UserBlock{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked", "user_id": target.UserID})
+}
+
+// GetBlockedUsers lists the users the caller has blocked, most recent first.
+func GetBlockedUsers(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	blocked := make([]blockedUser, 0)
+	if err := db.DB.Table("user_blocks").
+		Select("users.user_id, users.username, users.name, user_blocks.created_at AS blocked_at").
+		Joins("JOIN users ON users.user_id = user_blocks.blocked_id").
+		Where("user_blocks.blocker_id = ?", currentUser.UserID).
+		Order("user_blocks.created_at DESC").
+		Scan(&blocked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked_users": blocked})
+}
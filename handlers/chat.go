@@ -1,11 +1,13 @@
 package handlers
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"sync"
+	"time"
 
 	"GoCall_api/db"
+	"GoCall_api/metrics"
 	"GoCall_api/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -19,16 +21,159 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// Таймаут записи в сокет
+const chatWriteTimeout = 10 * time.Second
+
+// Размер очереди исходящих кадров одного сокета
+const chatSendBuffer = 64
+
+// chatClient is one connected chat socket. gorilla/websocket allows a single
+// concurrent writer: frames are queued with enqueue and written by writeLoop,
+// so publishers never wait on a slow socket.
+type chatClient struct {
+	userID string
+	conn   *websocket.Conn
+	send   chan interface{}
+	done   chan struct{}
+
+	// Собеседник, чей диалог открыт в клиенте; сообщения от него не создают уведомлений
+	openMu   sync.Mutex
+	openWith string
+}
+
+func newChatClient(userID string, conn *websocket.Conn) *chatClient {
+	return &chatClient{
+		userID: userID,
+		conn:   conn,
+		send:   make(chan interface{}, chatSendBuffer),
+		done:   make(chan struct{}),
+	}
+}
+
+// enqueue queues a frame for writeLoop without blocking. A socket whose queue
+// is full cannot keep up; it is closed and its read loop cleans it up.
+func (cl *chatClient) enqueue(v interface{}) bool {
+	select {
+	case <-cl.done:
+		return false
+	default:
+	}
+
+	select {
+	case cl.send <- v:
+		return true
+	default:
+		utils.Logger.Warn("chat send queue full, closing socket", "user_id", cl.userID)
+		cl.conn.Close()
+		return false
+	}
+}
+
+// writeLoop writes queued frames until the socket's handler returns.
+func (cl *chatClient) writeLoop() {
+	for {
+		select {
+		case v := <-cl.send:
+			cl.conn.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+			if err := cl.conn.WriteJSON(v); err != nil {
+				utils.Logger.Debug("chat write failed", "user_id", cl.userID, "error", err)
+				cl.conn.Close()
+				return
+			}
+		case <-cl.done:
+			return
+		}
+	}
+}
+
+func (cl *chatClient) setOpenConversation(userID string) {
+	cl.openMu.Lock()
+	cl.openWith = userID
+	cl.openMu.Unlock()
+}
+
+func (cl *chatClient) hasOpenConversation(userID string) bool {
+	cl.openMu.Lock()
+	defer cl.openMu.Unlock()
+	return cl.openWith != "" && cl.openWith == userID
+}
+
 // Хранилище для подключений
-// key - userID (UUID), value - канал для отправки сообщений
+// key - userID (UUID), value - подключение пользователя
 var chatClients = struct {
 	sync.RWMutex
-	clients map[string]*websocket.Conn
+	clients map[string]*chatClient
 }{
-	clients: make(map[string]*websocket.Conn),
+	clients: make(map[string]*chatClient),
+}
+
+// sendToUser queues a JSON payload for the user's chat socket if they are connected.
+// It never blocks the caller, e.g. a handler or another user's read loop.
+func sendToUser(userID string, payload interface{}) bool {
+	chatClients.RLock()
+	client, ok := chatClients.clients[userID]
+	chatClients.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if !client.enqueue(payload) {
+		utils.Logger.Warn("failed to push to chat socket", "user_id", userID)
+		return false
+	}
+	return true
+}
+
+// disconnectChatUser closes the user's chat socket, e.g. after their account is deleted.
+func disconnectChatUser(userID string) {
+	chatClients.RLock()
+	client, ok := chatClients.clients[userID]
+	chatClients.RUnlock()
+	if !ok {
+		return
+	}
+
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account deleted")
+	client.conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(time.Second))
+	client.conn.Close()
+}
+
+// Счётчик активных обработчиков сокетов, чтобы дождаться их при остановке
+var chatHandlers sync.WaitGroup
+
+// CloseChatClients sends a "going away" close frame to every open chat socket,
+// closes it and waits until the socket handlers return or ctx expires.
+func CloseChatClients(ctx context.Context) {
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(time.Second)
+
+	chatClients.RLock()
+	for userID, client := range chatClients.clients {
+		if err := client.conn.WriteControl(websocket.CloseMessage, closeFrame, deadline); err != nil {
+			utils.Logger.Warn("failed to send chat close frame", "user_id", userID, "error", err)
+		}
+		client.conn.Close()
+	}
+	chatClients.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		chatHandlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		utils.Logger.Warn("timed out waiting for chat sockets to close")
+	}
 }
 
 // HandleChatWebSocket upgrades the request and relays direct chat messages.
+// The same socket carries room events: clients send
+// {"type": "subscribe", "room_id": "..."} or {"type": "unsubscribe", "room_id": "..."}.
+// Clients report the conversation on screen with {"type": "open_conversation", "to": "..."}
+// (empty "to" when none); messages from anyone else create notifications.
 func HandleChatWebSocket(c *gin.Context) {
 	tokenString := c.Query("token")
 	if tokenString == "" {
@@ -49,36 +194,80 @@ func HandleChatWebSocket(c *gin.Context) {
 		return
 	}
 
+	chatHandlers.Add(1)
+	defer chatHandlers.Done()
+
+	utils.SetLogUser(c, user.UserID)
+	logger := utils.Log(c)
+
 	// Апгрейд соединения до WebSocket
 	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("Upgrade error:", err)
+		logger.Warn("chat websocket upgrade failed", "error", err)
 		return
 	}
 	defer wsConn.Close()
 
 	// Сохраняем подключение пользователя в памяти
+	client := newChatClient(user.UserID, wsConn)
+	go client.writeLoop()
 	chatClients.Lock()
-	chatClients.clients[user.UserID] = wsConn
+	chatClients.clients[user.UserID] = client
 	chatClients.Unlock()
-	log.Printf("User %s connected to chat\n", user.UserID)
+	metrics.ChatConnections.Inc()
+	logger.Info("chat connected")
 
 	for {
 		// Ожидаем JSON-объект вида: {"to": "...", "message": "..."}
+		// или {"type": "subscribe"|"unsubscribe", "room_id": "..."}
 		var incoming struct {
+			Type    string `json:"type"`
+			RoomID  string `json:"room_id"`
 			To      string `json:"to"`
 			Message string `json:"message"`
 		}
 
 		// Считываем JSON
 		if err := wsConn.ReadJSON(&incoming); err != nil {
-			log.Println("ReadJSON error:", err)
+			logger.Debug("chat read failed", "error", err)
 			break
 		}
 
+		// Подписка на события комнат
+		switch incoming.Type {
+		case "subscribe":
+			roomID, err := subscribeRoom(client, incoming.RoomID)
+			if err != nil {
+				logger.Debug("room subscription rejected", "room_id", incoming.RoomID, "error", err)
+				client.enqueue(gin.H{"type": "error", "room_id": incoming.RoomID, "error": err.Error()})
+				continue
+			}
+			client.enqueue(gin.H{"type": "subscribed", "room_id": roomID})
+			continue
+		case "unsubscribe":
+			roomID := unsubscribeRoom(client, incoming.RoomID)
+			client.enqueue(gin.H{"type": "unsubscribed", "room_id": roomID})
+			continue
+		case "open_conversation":
+			client.setOpenConversation(incoming.To)
+			continue
+		}
+
 		// Проверяем, что есть получатель
 		if incoming.To == "" {
-			log.Println("No recipient specified")
+			logger.Debug("chat message without recipient")
+			continue
+		}
+
+		// Блокировка в любую сторону — сообщение отбрасывается
+		blocked, err := isBlockedBetween(user.UserID, incoming.To)
+		if err != nil {
+			logger.Error("failed to check blocks", "to", incoming.To, "error", err)
+			continue
+		}
+		if blocked {
+			metrics.ChatMessages.WithLabelValues(metrics.ChatBlockedUser).Inc()
+			logger.Info("chat message dropped, users are blocked", "to", incoming.To)
 			continue
 		}
 
@@ -86,11 +275,12 @@ func HandleChatWebSocket(c *gin.Context) {
 		// Если надо ограничить общение только друзьям — раскомментируйте:
 		friends, err := areFriends(user.UserID, incoming.To)
 		if err != nil {
-			log.Printf("Failed to verify friendship for %s and %s: %v\n", user.UserID, incoming.To, err)
+			logger.Error("failed to verify friendship", "to", incoming.To, "error", err)
 			continue
 		}
 		if !friends {
-			log.Printf("Users %s and %s are not friends. Message blocked.\n", user.UserID, incoming.To)
+			metrics.ChatMessages.WithLabelValues(metrics.ChatBlockedFriends).Inc()
+			logger.Info("chat message blocked, users are not friends", "to", incoming.To)
 			continue
 		}
 
@@ -101,17 +291,17 @@ func HandleChatWebSocket(c *gin.Context) {
 			Text:       incoming.Message,
 		}
 		if err := db.DB.Create(&newMsg).Error; err != nil {
-			log.Println("DB Create error:", err)
+			logger.Error("failed to store chat message", "to", incoming.To, "error", err)
 			continue
 		}
 
 		// Пытаемся найти подключение получателя
 		chatClients.RLock()
-		receiverConn, ok := chatClients.clients[incoming.To]
+		receiver, ok := chatClients.clients[incoming.To]
 		chatClients.RUnlock()
 
 		// Если получатель в сети, отправляем сообщение
-		if ok && receiverConn != nil {
+		if ok && receiver != nil {
 			var outgoing = struct {
 				From    string `json:"from"`
 				To      string `json:"to"`
@@ -121,20 +311,32 @@ func HandleChatWebSocket(c *gin.Context) {
 				To:      incoming.To,
 				Message: incoming.Message,
 			}
-			if err := receiverConn.WriteJSON(outgoing); err != nil {
-				log.Println("WriteJSON error:", err)
+			if !receiver.enqueue(outgoing) {
+				logger.Warn("failed to relay chat message", "to", incoming.To)
+			} else {
+				metrics.ChatMessages.WithLabelValues(metrics.ChatRelayed).Inc()
+			}
+			if !receiver.hasOpenConversation(user.UserID) {
+				notifyMessage(incoming.To, user.UserID, incoming.Message)
 			}
 		} else {
 			// Иначе пользователь офлайн — сообщение уже сохранено в БД
-			log.Printf("User %s is offline. Message stored.\n", incoming.To)
+			metrics.ChatMessages.WithLabelValues(metrics.ChatStoredOffline).Inc()
+			logger.Debug("chat recipient offline, message stored", "to", incoming.To)
+			notifyMessage(incoming.To, user.UserID, incoming.Message)
 		}
 	}
 
-	// Удаляем подключение при разрыве
+	// Останавливаем запись, удаляем подписки и подключение при разрыве (если его не заменило более новое)
+	close(client.done)
+	unsubscribeAll(client)
 	chatClients.Lock()
-	delete(chatClients.clients, user.UserID)
+	if chatClients.clients[user.UserID] == client {
+		delete(chatClients.clients, user.UserID)
+	}
 	chatClients.Unlock()
-	log.Printf("User %s disconnected\n", user.UserID)
+	metrics.ChatConnections.Dec()
+	logger.Info("chat disconnected")
 }
 
 // areFriends reports whether two users have a stored friendship relation.
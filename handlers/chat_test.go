@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialChatClient connects a websocket pair and wraps the server side in a
+// chatClient whose writeLoop is not running, so its queue never drains.
+func dialChatClient(t *testing.T) (*chatClient, *websocket.Conn) {
+	t.Helper()
+	serverConn := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		serverConn <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { peer.Close() })
+
+	conn := <-serverConn
+	t.Cleanup(func() { conn.Close() })
+	return newChatClient(testUserID, conn), peer
+}
+
+func TestChatClientEnqueueNeverBlocks(t *testing.T) {
+	client, peer := dialChatClient(t)
+
+	for i := 0; i < chatSendBuffer; i++ {
+		if !client.enqueue(i) {
+			t.Fatalf("frame %d rejected before the queue was full", i)
+		}
+	}
+
+	done := make(chan bool)
+	go func() { done <- client.enqueue("overflow") }()
+	select {
+	case queued := <-done:
+		if queued {
+			t.Fatal("frame queued beyond the buffer")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("enqueue blocked on a full queue")
+	}
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := peer.ReadMessage(); err == nil {
+		t.Fatal("slow socket was not closed")
+	}
+}
+
+func TestChatClientWriteLoop(t *testing.T) {
+	client, peer := dialChatClient(t)
+	go client.writeLoop()
+	t.Cleanup(func() { close(client.done) })
+
+	client.enqueue(roomEvent{Type: "room_event", Event: roomEventMemberJoined, RoomID: testRoomID})
+
+	var frame roomEvent
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	if err := peer.ReadJSON(&frame); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if frame.Event != roomEventMemberJoined || frame.RoomID != testRoomID {
+		t.Fatalf("frame = %+v", frame)
+	}
+}
+
+func TestSendToUserDoesNotBlockOnSlowSocket(t *testing.T) {
+	client, _ := dialChatClient(t)
+	chatClients.Lock()
+	chatClients.clients[testUserID] = client
+	chatClients.Unlock()
+	t.Cleanup(func() {
+		chatClients.Lock()
+		delete(chatClients.clients, testUserID)
+		chatClients.Unlock()
+	})
+
+	for i := 0; i < chatSendBuffer; i++ {
+		client.enqueue(i)
+	}
+
+	done := make(chan bool)
+	go func() { done <- sendToUser(testUserID, map[string]string{"type": "notification"}) }()
+	select {
+	case sent := <-done:
+		if sent {
+			t.Fatal("notification queued beyond the buffer")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sendToUser blocked on a full queue")
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roomPeer is the other participant of a direct room, as seen by the caller.
+type roomPeer struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	IsOnline bool   `json:"is_online"`
+}
+
+// roomWithPeer is a room as returned to a member; Peer is set for direct rooms only.
+type roomWithPeer struct {
+	db.Room
+	Peer *roomPeer `json:"peer,omitempty"`
+}
+
+// newRoomPeer builds the peer view of user; onlineVisible is false when the
+// user's online_status privacy setting hides their presence from the viewer.
+func newRoomPeer(user *db.User, onlineVisible bool) *roomPeer {
+	return &roomPeer{
+		UserID:   user.UserID,
+		Username: user.Username,
+		Name:     user.Name,
+		IsOnline: user.IsOnline && onlineVisible,
+	}
+}
+
+// withRoomPeers attaches the other participant to every direct room in rooms.
+func withRoomPeers(rooms []db.Room, viewerID string) ([]roomWithPeer, error) {
+	response := make([]roomWithPeer, len(rooms))
+	directRoomIDs := make([]string, 0)
+	for i := range rooms {
+		response[i].Room = rooms[i]
+		if rooms[i].Kind == db.RoomKindDirect {
+			directRoomIDs = append(directRoomIDs, rooms[i].RoomID)
+		}
+	}
+	if len(directRoomIDs) == 0 {
+		return response, nil
+	}
+
+	var peers []struct {
+		RoomID string
+		db.User
+	}
+	if err := db.DB.Table("room_members").
+		Select("room_members.room_id, users.*").
+		Joins("JOIN users ON users.user_id = room_members.user_id").
+		Where("room_members.room_id IN ? AND room_members.user_id <> ?", directRoomIDs, viewerID).
+		Scan(&peers).Error; err != nil {
+		return nil, err
+	}
+
+	peerIDs := make([]string, len(peers))
+	for i := range peers {
+		peerIDs[i] = peers[i].UserID
+	}
+	hidden, err := hiddenOnlineStatus(viewerID, peerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	peerByRoom := make(map[string]*roomPeer, len(peers))
+	for i := range peers {
+		peerByRoom[peers[i].RoomID] = newRoomPeer(&peers[i].User, !hidden[peers[i].UserID])
+	}
+	for i := range response {
+		response[i].Peer = peerByRoom[response[i].RoomID]
+	}
+	return response, nil
+}
+
+// rejectDirectRoom answers 409 for operations that only make sense in group rooms.
+func rejectDirectRoom(c *gin.Context, room *db.Room) bool {
+	if room.Kind != db.RoomKindDirect {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Direct rooms cannot be changed"})
+	return true
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxFriendGroupName = 50
+
+// friendGroupResponse is a friend group with the UUIDs of its members.
+type friendGroupResponse struct {
+	db.FriendGroup
+	MemberIDs []string `json:"member_ids"`
+}
+
+// loadFriendGroup resolves the :id group owned by ownerID, answering 404 otherwise.
+func loadFriendGroup(c *gin.Context, ownerID string) (*db.FriendGroup, bool) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend group not found"})
+		return nil, false
+	}
+	return findFriendGroup(c, ownerID, uint(groupID))
+}
+
+// findFriendGroup fetches a group by ID if ownerID owns it, answering 404 otherwise.
+func findFriendGroup(c *gin.Context, ownerID string, groupID uint) (*db.FriendGroup, bool) {
+	var group db.FriendGroup
+	if err := db.DB.Where("id = ? AND owner_id = ?", groupID, ownerID).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Friend group not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend group"})
+		}
+		return nil, false
+	}
+	return &group, true
+}
+
+// friendGroupMemberIDs returns the UUIDs of the group's members.
+func friendGroupMemberIDs(groupID uint) ([]string, error) {
+	memberIDs := make([]string, 0)
+	err := db.DB.Model(&db.FriendGroupMember{}).Where("group_id = ?", groupID).Order("id ASC").Pluck("friend_id", &memberIDs).Error
+	return memberIDs, err
+}
+
+// normalizeFriendGroupName trims the name and checks its length.
+func normalizeFriendGroupName(c *gin.Context, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxFriendGroupName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group name must be 1-50 characters"})
+		return "", false
+	}
+	return name, true
+}
+
+// friendGroupNameTaken reports whether the owner already has another group with this name.
+func friendGroupNameTaken(ownerID, name string, exceptID uint) (bool, error) {
+	var count int64
+	err := db.DB.Model(&db.FriendGroup{}).Where("owner_id = ? AND name = ? AND id <> ?", ownerID, name, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// removeFromFriendGroups takes each user out of the other's friend groups, e.g. after an unfriend.
+func removeFromFriendGroups(tx *gorm.DB, userA, userB string) error {
+	ownedBy := func(ownerID string) *gorm.DB {
+		return tx.Model(&db.FriendGroup{}).Select("id").Where("owner_id = ?", ownerID)
+	}
+	return tx.Where("(friend_id = ? AND group_id IN (?)) OR (friend_id = ? AND group_id IN (?))", userB, ownedBy(userA), userA, ownedBy(userB)).
+		Delete(&db.FriendGroupMember{}).Error
+}
+
+// GetFriendGroups lists the caller's friend groups in their chosen order.
+func GetFriendGroups(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var groups []db.FriendGroup
+	if err := db.DB.Where("owner_id = ?", currentUser.UserID).Order("position ASC, id ASC").Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend groups"})
+		return
+	}
+
+	groupIDs := make([]uint, len(groups))
+	for i := range groups {
+		groupIDs[i] = groups[i].ID
+	}
+	var members []db.FriendGroupMember
+	if len(groupIDs) > 0 {
+		if err := db.DB.Where("group_id IN ?", groupIDs).Order("id ASC").Find(&members).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend group members"})
+			return
+		}
+	}
+	memberIDs := make(map[uint][]string, len(groups))
+	for _, member := range members {
+		memberIDs[member.GroupID] = append(memberIDs[member.GroupID], member.FriendID)
+	}
+
+	response := make([]friendGroupResponse, len(groups))
+	for i, group := range groups {
+		response[i] = friendGroupResponse{FriendGroup: group, MemberIDs: memberIDs[group.ID]}
+		if response[i].MemberIDs == nil {
+			response[i].MemberIDs = []string{}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": response})
+}
+
+// CreateFriendGroup creates an empty friend group at the end of the caller's list.
+func CreateFriendGroup(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'name' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	name, ok := normalizeFriendGroupName(c, req.Name)
+	if !ok {
+		return
+	}
+
+	if taken, err := friendGroupNameTaken(currentUser.UserID, name, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create friend group"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A group with this name already exists"})
+		return
+	}
+
+	var position int
+	if err := db.DB.Model(&db.FriendGroup{}).Where("owner_id = ?", currentUser.UserID).
+		Select("COALESCE(MAX(position) + 1, 0)").Scan(&position).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create friend group"})
+		return
+	}
+
+	group := db.FriendGroup{OwnerID: currentUser.UserID, Name: name, Position: position}
+	if err := db.DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create friend group"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"group": friendGroupResponse{FriendGroup: group, MemberIDs: []string{}}})
+}
+
+// RenameFriendGroup changes a group's name.
+func RenameFriendGroup(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'name' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	group, ok := loadFriendGroup(c, currentUser.UserID)
+	if !ok {
+		return
+	}
+	name, ok := normalizeFriendGroupName(c, req.Name)
+	if !ok {
+		return
+	}
+
+	if taken, err := friendGroupNameTaken(currentUser.UserID, name, group.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename friend group"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A group with this name already exists"})
+		return
+	}
+
+	group.Name = name
+	if err := db.DB.Model(group).Update("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename friend group"})
+		return
+	}
+
+	memberIDs, err := friendGroupMemberIDs(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend group members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"group": friendGroupResponse{FriendGroup: *group, MemberIDs: memberIDs}})
+}
+
+// ReorderFriendGroups sets the order of the caller's groups. group_ids must list every group exactly once.
+func ReorderFriendGroups(c *gin.Context) {
+	var req struct {
+		GroupIDs []uint `json:"group_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'group_ids' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var ownedIDs []uint
+	if err := db.DB.Model(&db.FriendGroup{}).Where("owner_id = ?", currentUser.UserID).Pluck("id", &ownedIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend groups"})
+		return
+	}
+	owned := make(map[uint]bool, len(ownedIDs))
+	for _, id := range ownedIDs {
+		owned[id] = true
+	}
+	seen := make(map[uint]bool, len(req.GroupIDs))
+	for _, id := range req.GroupIDs {
+		if !owned[id] || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_ids must list each of your groups exactly once"})
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(owned) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_ids must list each of your groups exactly once"})
+		return
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range req.GroupIDs {
+			if err := tx.Model(&db.FriendGroup{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder friend groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend groups reordered", "group_ids": req.GroupIDs})
+}
+
+// DeleteFriendGroup deletes a group. The friendships themselves are kept.
+func DeleteFriendGroup(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	group, ok := loadFriendGroup(c, currentUser.UserID)
+	if !ok {
+		return
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&db.FriendGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete friend group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend group deleted", "group_id": group.ID})
+}
+
+// AddFriendGroupMembers adds friends to a group. Every user must be a friend of the caller;
+// users already in the group are ignored.
+func AddFriendGroupMembers(c *gin.Context) {
+	var req struct {
+		UserIDs []string `json:"user_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'user_ids' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	group, ok := loadFriendGroup(c, currentUser.UserID)
+	if !ok {
+		return
+	}
+
+	var friendIDs []string
+	if err := db.DB.Model(&db.Friend{}).Where("user_id = ? AND friend_id IN ?", currentUser.UserID, req.UserIDs).
+		Pluck("friend_id", &friendIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify friendships"})
+		return
+	}
+	isFriend := make(map[string]bool, len(friendIDs))
+	for _, id := range friendIDs {
+		isFriend[id] = true
+	}
+	notFriends := make([]string, 0)
+	for _, id := range req.UserIDs {
+		if !isFriend[id] {
+			notFriends = append(notFriends, id)
+		}
+	}
+	if len(notFriends) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only friends can be added to a group", "user_ids": notFriends})
+		return
+	}
+
+	members := make([]db.FriendGroupMember, 0, len(friendIDs))
+	for _, id := range friendIDs {
+		members = append(members, db.FriendGroupMember{GroupID: group.ID, FriendID: id})
+	}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend group members"})
+		return
+	}
+
+	memberIDs, err := friendGroupMemberIDs(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend group members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"group": friendGroupResponse{FriendGroup: *group, MemberIDs: memberIDs}})
+}
+
+// RemoveFriendGroupMember takes a friend out of a group.
+func RemoveFriendGroupMember(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	group, ok := loadFriendGroup(c, currentUser.UserID)
+	if !ok {
+		return
+	}
+
+	result := db.DB.Where("group_id = ? AND friend_id = ?", group.ID, c.Param("uuid")).Delete(&db.FriendGroupMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove friend group member"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not in this group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from friend group", "group_id": group.ID, "user_id": c.Param("uuid")})
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FriendUser is a friend-list entry, shared by GET /friends and GET /friends/pinned.
+type FriendUser struct {
+	ID          uint               `json:"id"` // users.id of the friend
+	UserID      string             `json:"user_id"`
+	Username    string             `json:"username"`
+	Name        string             `json:"name"`
+	IsOnline    bool               `json:"is_online"`
+	IsPinned    bool               `json:"is_pinned"`
+	PinPosition *int               `json:"pin_position"` // nil unless pinned
+	CreatedAt   time.Time          `json:"created_at"`   // when the friendship started
+	LastMessage *friendLastMessage `json:"last_message"` // nil when the pair never chatted
+}
+
+// friendLastMessage is the preview of the latest direct message between the caller and a friend.
+type friendLastMessage struct {
+	ID        uint      `json:"id"`
+	SenderID  string    `json:"sender_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// friendListRow is the flat result of the friend-list query.
+type friendListRow struct {
+	ID               uint
+	UserID           string
+	Username         string
+	Name             string
+	IsOnline         bool
+	IsPinned         bool
+	PinPosition      int
+	CreatedAt        time.Time
+	MessageID        *uint
+	MessageSenderID  *string
+	MessageText      *string
+	MessageCreatedAt *time.Time
+}
+
+// queryFriendList loads the caller's friends with presence and the last message in
+// one query: pinned friends first in pin order, then everyone else by username.
+// A non-zero groupID restricts the list to the members of that friend group.
+func queryFriendList(userID string, pinnedOnly bool, groupID uint) ([]FriendUser, error) {
+	query := db.DB.Table("friends f").
+		Select(`u.id, u.user_id, u.username, u.name, u.is_online, f.is_pinned, f.pin_position, f.created_at,
+			m.id AS message_id, m.sender_id AS message_sender_id, m.text AS message_text, m.created_at AS message_created_at`).
+		Joins("JOIN users u ON u.user_id = f.friend_id").
+		Joins(`LEFT JOIN messages m ON m.id = (
+			SELECT id FROM messages
+			WHERE (sender_id = f.user_id AND receiver_id = f.friend_id) OR (sender_id = f.friend_id AND receiver_id = f.user_id)
+			ORDER BY id DESC LIMIT 1)`).
+		Where("f.user_id = ?", userID)
+	if pinnedOnly {
+		query = query.Where("f.is_pinned = ?", true)
+	}
+	if groupID != 0 {
+		query = query.Joins("JOIN friend_group_members gm ON (gm.friend_id = f.friend_id AND gm.group_id = ?)", groupID)
+	}
+
+	var rows []friendListRow
+	if err := query.Order("f.is_pinned DESC, f.pin_position ASC, u.username ASC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	friendIDs := make([]string, len(rows))
+	for i, row := range rows {
+		friendIDs[i] = row.UserID
+	}
+	hidden, err := hiddenOnlineStatus(userID, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	friends := make([]FriendUser, len(rows))
+	for i, row := range rows {
+		friends[i] = FriendUser{
+			ID:        row.ID,
+			UserID:    row.UserID,
+			Username:  row.Username,
+			Name:      row.Name,
+			IsOnline:  row.IsOnline && !hidden[row.UserID],
+			IsPinned:  row.IsPinned,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.IsPinned {
+			position := row.PinPosition
+			friends[i].PinPosition = &position
+		}
+		if row.MessageID != nil {
+			last := &friendLastMessage{ID: *row.MessageID}
+			if row.MessageSenderID != nil {
+				last.SenderID = *row.MessageSenderID
+			}
+			if row.MessageText != nil {
+				last.Text = messagePreview(*row.MessageText)
+			}
+			if row.MessageCreatedAt != nil {
+				last.CreatedAt = *row.MessageCreatedAt
+			}
+			friends[i].LastMessage = last
+		}
+	}
+	return friends, nil
+}
+
+// loadPinTarget resolves the friendship addressed by a pin request, either by the
+// friend's UUID or by the legacy numeric users.id, answering 400/404 itself.
+func loadPinTarget(c *gin.Context, currentUserID, friendUUID string, legacyID uint) (*db.Friend, bool) {
+	if friendUUID == "" {
+		if legacyID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'user_id' is required"})
+			return nil, false
+		}
+		var friendUser db.User
+		if err := db.DB.First(&friendUser, legacyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Friend user not found"})
+			return nil, false
+		}
+		friendUUID = friendUser.UserID
+	}
+
+	var friendship db.Friend
+	if err := db.DB.Where("user_id = ? AND friend_id = ?", currentUserID, friendUUID).First(&friendship).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You are not friends with this user"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch friendship"})
+		}
+		return nil, false
+	}
+	return &friendship, true
+}
+
+// ReorderPinnedFriends sets the display order of pinned friends.
+// request body: { "user_ids": ["UUID-1", "UUID-2"] } - every pinned friend exactly once
+func ReorderPinnedFriends(c *gin.Context) {
+	var req struct {
+		UserIDs []string `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. 'user_ids' is required"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var pinnedIDs []string
+	if err := db.DB.Model(&db.Friend{}).
+		Where("user_id = ? AND is_pinned = ?", currentUser.UserID, true).
+		Pluck("friend_id", &pinnedIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch pinned friends"})
+		return
+	}
+	pinned := make(map[string]bool, len(pinnedIDs))
+	for _, id := range pinnedIDs {
+		pinned[id] = true
+	}
+	seen := make(map[string]bool, len(req.UserIDs))
+	for _, id := range req.UserIDs {
+		if !pinned[id] || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids must list each of your pinned friends exactly once"})
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(pinned) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids must list each of your pinned friends exactly once"})
+		return
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range req.UserIDs {
+			if err := tx.Model(&db.Friend{}).
+				Where("user_id = ? AND friend_id = ?", currentUser.UserID, id).
+				Update("pin_position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reorder pinned friends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pinned friends reordered", "user_ids": req.UserIDs})
+}
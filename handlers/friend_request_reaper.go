@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+)
+
+// StartFriendRequestReaper expires pending friend requests older than ttl and
+// forgets declines whose cooldown has passed, checking every interval. The
+// returned channel is closed once the reaper has stopped after ctx is cancelled.
+func StartFriendRequestReaper(ctx context.Context, ttl, cooldown, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				expired, purged, err := reapFriendRequests(ttl, cooldown)
+				if err != nil {
+					utils.Logger.Error("friend request reaper failed", "error", err)
+					continue
+				}
+				if expired > 0 || purged > 0 {
+					utils.Logger.Info("cleaned up friend requests", "expired", expired, "declined_purged", purged)
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// reapFriendRequests deletes pending requests created before now-ttl, together with
+// their recipients' notifications, and declined requests that no longer hold back a new request.
+func reapFriendRequests(ttl, cooldown time.Duration) (int64, int64, error) {
+	now := time.Now()
+
+	var stale []db.FriendRequest
+	if err := db.DB.Where("status = 'pending' AND created_at < ?", now.Add(-ttl)).Find(&stale).Error; err != nil {
+		return 0, 0, err
+	}
+	var expired int64
+	for _, request := range stale {
+		result := db.DB.Where("id = ? AND status = 'pending'", request.ID).Delete(&db.FriendRequest{})
+		if result.Error != nil {
+			return expired, 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			expired++
+			retractNotification(request.ToUserID, notificationFriendRequest, request.ID)
+		}
+	}
+
+	purged := db.DB.Where("status = 'declined' AND COALESCE(responded_at, created_at) < ?", now.Add(-cooldown)).Delete(&db.FriendRequest{})
+	if purged.Error != nil {
+		return expired, 0, purged.Error
+	}
+
+	return expired, purged.RowsAffected, nil
+}
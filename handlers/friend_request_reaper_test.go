@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+)
+
+// seedStaleFriendRequest stores a pending request from testUserID to testTargetID that
+// has outlived friends.request_ttl, with the recipient's notification.
+func seedStaleFriendRequest(t *testing.T) db.FriendRequest {
+	t.Helper()
+	request := db.FriendRequest{FromUserID: testUserID, ToUserID: testTargetID, Status: "pending"}
+	if err := db.DB.Create(&request).Error; err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	createdAt := time.Now().Add(-config.Current.Friends.RequestTTL.Duration - time.Minute)
+	if err := db.DB.Model(&request).Update("created_at", createdAt).Error; err != nil {
+		t.Fatalf("backdate request: %v", err)
+	}
+	notification := db.Notification{UserID: testTargetID, Type: notificationFriendRequest, ActorID: testUserID, RefID: request.ID}
+	if err := db.DB.Create(&notification).Error; err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+	return request
+}
+
+func TestReapFriendRequestsRetractsNotifications(t *testing.T) {
+	setupTestDB(t)
+	seedStaleFriendRequest(t)
+
+	expired, _, err := reapFriendRequests(config.Current.Friends.RequestTTL.Duration, config.Current.Friends.RequestCooldown.Duration)
+	if err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	if expired != 1 {
+		t.Fatalf("expired = %d, want 1", expired)
+	}
+
+	var requests, notifications int64
+	db.DB.Model(&db.FriendRequest{}).Count(&requests)
+	db.DB.Model(&db.Notification{}).Count(&notifications)
+	if requests != 0 || notifications != 0 {
+		t.Fatalf("%d requests and %d notifications left, want none", requests, notifications)
+	}
+}
+
+func TestAcceptExpiredFriendRequest(t *testing.T) {
+	setupTestDB(t)
+	seedUser(t, testUserID, "alice")
+	recipientID := seedUser(t, testTargetID, "bob")
+	request := seedStaleFriendRequest(t)
+
+	w := callHandler(AcceptFriendRequest, recipientID, `{"request_id":`+strconv.FormatUint(uint64(request.ID), 10)+`}`)
+	if w.Code != http.StatusGone {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusGone, w.Body)
+	}
+	var friends int64
+	db.DB.Model(&db.Friend{}).Count(&friends)
+	if friends != 0 {
+		t.Fatal("expired request created a friendship")
+	}
+}
+
+func TestRequestFriendIgnoresExpiredRequests(t *testing.T) {
+	setupTestDB(t)
+	senderID := seedUser(t, testUserID, "alice")
+	recipientID := seedUser(t, testTargetID, "bob")
+	seedStaleFriendRequest(t)
+
+	// Истёкшая встречная заявка не принимается автоматически
+	w := callHandler(RequestFriend, recipientID, `{"to_username":"alice"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("incoming: status = %d, body = %s", w.Code, w.Body)
+	}
+	var friends int64
+	db.DB.Model(&db.Friend{}).Count(&friends)
+	if friends != 0 {
+		t.Fatal("expired incoming request was accepted")
+	}
+
+	// Истёкшая исходящая заявка не мешает отправить новую
+	if err := db.DB.Where("from_user_id = ?", testTargetID).Delete(&db.FriendRequest{}).Error; err != nil {
+		t.Fatalf("delete new request: %v", err)
+	}
+	if w := callHandler(RequestFriend, senderID, `{"to_username":"bob"}`); w.Code != http.StatusOK {
+		t.Fatalf("outgoing: status = %d, body = %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// outgoingFriendRequest is a pending request sent by the caller, with the recipient's profile.
+type outgoingFriendRequest struct {
+	ID        uint        `json:"id"`
+	To        userProfile `json:"to"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// userProfile is the public profile attached to friend request and suggestion listings.
+type userProfile struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// friendRequestExpired reports whether a pending request has outlived friends.request_ttl.
+// The reaper deletes such requests, but only on its next tick.
+func friendRequestExpired(request *db.FriendRequest, now time.Time) bool {
+	return !now.Before(request.CreatedAt.Add(config.Current.Friends.RequestTTL.Duration))
+}
+
+// friendRequestCooldown returns how long fromUserID must still wait before asking
+// toUserID again after their last decline, or 0 when they may ask now.
+func friendRequestCooldown(fromUserID, toUserID string) (time.Duration, error) {
+	cooldown := config.Current.Friends.RequestCooldown.Duration
+	if cooldown <= 0 {
+		return 0, nil
+	}
+
+	var declined db.FriendRequest
+	err := db.DB.Where("from_user_id = ? AND to_user_id = ? AND status = 'declined'", fromUserID, toUserID).
+		Order("COALESCE(responded_at, created_at) DESC").
+		First(&declined).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	declinedAt := declined.CreatedAt
+	if declined.RespondedAt != nil {
+		declinedAt = *declined.RespondedAt
+	}
+	if remaining := time.Until(declinedAt.Add(cooldown)); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// GetOutgoingFriendRequests lists the caller's pending sent requests, newest first.
+func GetOutgoingFriendRequests(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		ID        uint
+		CreatedAt time.Time
+		UserID    string
+		Username  string
+		Name      string
+	}
+	if err := db.DB.Table("friend_requests").
+		Select("friend_requests.id, friend_requests.created_at, users.user_id, users.username, users.name").
+		Joins("JOIN users ON users.user_id = friend_requests.to_user_id").
+		Where("friend_requests.from_user_id = ? AND friend_requests.status = 'pending'", currentUser.UserID).
+		Where("friend_requests.created_at > ?", time.Now().Add(-config.Current.Friends.RequestTTL.Duration)).
+		Order("friend_requests.created_at DESC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch friend requests"})
+		return
+	}
+
+	ttl := config.Current.Friends.RequestTTL.Duration
+	requests := make([]outgoingFriendRequest, len(rows))
+	for i, row := range rows {
+		requests[i] = outgoingFriendRequest{
+			ID:        row.ID,
+			To:        userProfile{UserID: row.UserID, Username: row.Username, Name: row.Name},
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.CreatedAt.Add(ttl),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"outgoing_requests": requests})
+}
+
+// CancelFriendRequest withdraws a pending request sent by the caller.
+func CancelFriendRequest(c *gin.Context) {
+	var req struct {
+		RequestID uint `json:"request_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var fr db.FriendRequest
+	if err := db.DB.First(&fr, req.RequestID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return
+	}
+	if fr.FromUserID != currentUser.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to cancel this request"})
+		return
+	}
+
+	result := db.DB.Where("id = ? AND status = 'pending'", fr.ID).Delete(&db.FriendRequest{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not cancel request"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request is not pending"})
+		return
+	}
+
+	retractNotification(fr.ToUserID, notificationFriendRequest, fr.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request cancelled", "request_id": fr.ID})
+}
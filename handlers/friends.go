@@ -1,47 +1,41 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
+	"GoCall_api/config"
 	"GoCall_api/db"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// FriendUser represents a friend entry returned by friendship endpoints.
-type FriendUser struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	IsOnline  bool      `json:"is_online"`
-	UserID    string    `json:"user_id"`
-	IsPinned  bool      `json:"is_pinned"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
-// GetFriends returns all accepted friends
+// GetFriends returns all accepted friends, optionally only those in ?group=<id>.
+// Pinned friends come first in pin order, the rest are sorted by username.
 func GetFriends(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
 		return
 	}
-	uid := userID.(uint)
 
-	var currentUser db.User
-	if err := db.DB.First(&currentUser, uid).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authenticated user"})
-		return
+	var groupID uint
+	if groupParam := c.Query("group"); groupParam != "" {
+		parsed, err := strconv.ParseUint(groupParam, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'group' parameter"})
+			return
+		}
+		group, ok := findFriendGroup(c, currentUser.UserID, uint(parsed))
+		if !ok {
+			return
+		}
+		groupID = group.ID
 	}
 
-	var friends []FriendUser
-	err := db.DB.Raw(`
-		SELECT DISTINCT u.id, u.username, u.is_online, u.user_id, f.is_pinned, f.created_at
-		FROM users u
-		JOIN friends f
-		ON (f.friend_id = u.user_id AND f.user_id = ?)
-	`, currentUser.UserID, currentUser.UserID).Scan(&friends).Error
-
+	friends, err := queryFriendList(currentUser.UserID, false, groupID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friends"})
 		return
@@ -50,21 +44,9 @@ func GetFriends(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"friends": friends})
 }
 
-// AddFriend creates a friendship immediately without a request workflow.
+// AddFriend creates a friendship immediately without a request workflow. Only server
+// admins may do this, unless the target opted in via allow_direct_add.
 func AddFriend(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	uid := userID.(uint)
-
-	var currentUser db.User
-	if err := db.DB.First(&currentUser, uid).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authenticated user"})
-		return
-	}
-
 	var req struct {
 		FriendUsername string `json:"friend_username" binding:"required"`
 	}
@@ -73,32 +55,50 @@ func AddFriend(c *gin.Context) {
 		return
 	}
 
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
 	var friend db.User
 	if err := db.DB.Where("username = ?", req.FriendUsername).First(&friend).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User with this username not found"})
 		return
 	}
-
-	var existingFriend db.Friend
-	if err := db.DB.Where("user_id = ? AND friend_id = ?", currentUser.UserID, friend.UserID).First(&existingFriend).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Already friends"})
+	if friend.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot add yourself"})
 		return
 	}
 
-	newFriend1 := db.Friend{
-		UserID:   currentUser.UserID,
-		FriendID: friend.UserID,
+	if !config.Current.Auth.IsAdmin(currentUser.UserID) {
+		privacy, err := loadUserPrivacy(friend.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+			return
+		}
+		if !privacy.AllowDirectAdd {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User only accepts friend requests"})
+			return
+		}
 	}
-	if err := db.DB.Create(&newFriend1).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
+
+	rel, err := relationshipBetween(db.DB, currentUser.UserID, friend.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch relationship"})
 		return
 	}
-
-	newFriend2 := db.Friend{
-		UserID:   friend.UserID,
-		FriendID: currentUser.UserID,
+	switch rel.State {
+	case relationBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot add this user"})
+		return
+	case relationFriends:
+		c.JSON(http.StatusConflict, gin.H{"error": "Already friends"})
+		return
 	}
-	if err := db.DB.Create(&newFriend2).Error; err != nil {
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return createFriendship(tx, currentUser.UserID, friend.UserID)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
 		return
 	}
@@ -143,11 +143,17 @@ func RemoveFriend(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove friend"})
 		return
 	}
+	if err := removeFromFriendGroups(db.DB, currentUser.UserID, friend.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove friend"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Friend removed"})
 }
 
-// RequestFriend creates a pending friend request to another user.
+// RequestFriend creates a pending friend request to another user. If the other user
+// already asked the caller, their request is accepted instead. The target's
+// friend_requests privacy setting decides who may ask.
 func RequestFriend(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -170,18 +176,73 @@ func RequestFriend(c *gin.Context) {
 		return
 	}
 
-	var toUser db.User
-	if err := db.DB.Where("username = ?", req.ToUsername).First(&toUser).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Target user not found"})
+	toUser, err := findVisibleUser(currentUser.UserID, "username = ?", req.ToUsername)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target user not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch target user"})
+		}
+		return
+	}
+	if toUser.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send a friend request to yourself"})
 		return
 	}
 
-	// Check if a request already exists
-	var fr db.FriendRequest
-	if err := db.DB.Where("from_user_id = ? AND to_user_id = ? AND status = 'pending'",
-		currentUser.UserID, toUser.UserID).First(&fr).Error; err == nil {
+	rel, err := relationshipBetween(db.DB, currentUser.UserID, toUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch relationship"})
+		return
+	}
+	switch rel.State {
+	case relationBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot send a friend request to this user"})
+		return
+	case relationFriends:
+		c.JSON(http.StatusConflict, gin.H{"error": "Already friends"})
+		return
+	case relationOutgoing:
 		c.JSON(http.StatusConflict, gin.H{"error": "Friend request already sent"})
 		return
+	case relationIncoming:
+		// Встречная заявка — сразу принимаем её
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			return createFriendship(tx, currentUser.UserID, toUser.UserID)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept request"})
+			return
+		}
+		resolveNotification(currentUser.UserID, notificationFriendRequest, rel.RequestID)
+		notify(db.Notification{UserID: toUser.UserID, Type: notificationFriendAccepted, ActorID: currentUser.UserID, RefID: rel.RequestID})
+		c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted", "request_id": rel.RequestID, "state": relationFriends})
+		return
+	}
+
+	privacy, err := loadUserPrivacy(toUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch privacy settings"})
+		return
+	}
+	if allowed, err := audienceAllows(privacy.FriendRequests, currentUser.UserID, toUser.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check privacy settings"})
+		return
+	} else if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user does not accept friend requests from you"})
+		return
+	}
+
+	remaining, err := friendRequestCooldown(currentUser.UserID, toUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check request cooldown"})
+		return
+	}
+	if remaining > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Friend request was declined recently",
+			"retry_after": int(remaining.Seconds()) + 1,
+		})
+		return
 	}
 
 	newRequest := db.FriendRequest{
@@ -193,8 +254,9 @@ func RequestFriend(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create friend request"})
 		return
 	}
+	notify(db.Notification{UserID: toUser.UserID, Type: notificationFriendRequest, ActorID: currentUser.UserID, RefID: newRequest.ID})
 
-	c.JSON(http.StatusOK, gin.H{"message": "Friend request sent"})
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request sent", "request_id": newRequest.ID, "state": relationOutgoing})
 }
 
 // AcceptFriendRequest accepts a pending friend request and creates friendships.
@@ -234,26 +296,27 @@ func AcceptFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Request is not pending"})
 		return
 	}
-
-	fr.Status = "accepted"
-	if err := db.DB.Save(&fr).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept request"})
+	if friendRequestExpired(&fr, time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Friend request has expired"})
 		return
 	}
 
-	// Create friendship
-	newFriend1 := db.Friend{
-		UserID:    fr.FromUserID,
-		FriendID:  fr.ToUserID,
-		CreatedAt: time.Now(),
+	if blocked, err := isBlockedBetween(fr.FromUserID, fr.ToUserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check blocks"})
+		return
+	} else if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot accept a request from this user"})
+		return
 	}
-	db.DB.Create(&newFriend1)
-	newFriend2 := db.Friend{
-		UserID:    fr.ToUserID,
-		FriendID:  fr.FromUserID,
-		CreatedAt: time.Now(),
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return createFriendship(tx, fr.FromUserID, fr.ToUserID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept request"})
+		return
 	}
-	db.DB.Create(&newFriend2)
+	resolveNotification(currentUser.UserID, notificationFriendRequest, fr.ID)
+	notify(db.Notification{UserID: fr.FromUserID, Type: notificationFriendAccepted, ActorID: currentUser.UserID, RefID: fr.ID})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted"})
 }
@@ -296,11 +359,14 @@ func DeclineFriendRequest(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
 	fr.Status = "declined"
+	fr.RespondedAt = &now
 	if err := db.DB.Save(&fr).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decline request"})
 		return
 	}
+	resolveNotification(currentUser.UserID, notificationFriendRequest, fr.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Friend request declined"})
 }
@@ -321,7 +387,8 @@ func GetFriendRequests(c *gin.Context) {
 	}
 
 	var friendRequests []db.FriendRequest
-	if err := db.DB.Where("to_user_id = ? AND status = 'pending'", currentUser.UserID).Find(&friendRequests).Error; err != nil {
+	if err := db.DB.Where("to_user_id = ? AND status = 'pending' AND created_at > ?", currentUser.UserID, time.Now().Add(-config.Current.Friends.RequestTTL.Duration)).
+		Find(&friendRequests).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch friend requests"})
 		return
 	}
@@ -329,150 +396,104 @@ func GetFriendRequests(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"friend_requests": friendRequests})
 }
 
-// PinFriend sets is_pinned = true on the existing friendship row
-// request body: { "friend_id": 123 } - this is the DB's numeric ID, not the UUID
+// PinFriend pins a friend at the end of the pinned list
+// request body: { "user_id": "UUID" } - the legacy numeric { "friend_id": 123 } is still accepted
 func PinFriend(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	uid := userID.(uint)
-
-	// We find the user by its DB ID
-	var currentUser db.User
-	if err := db.DB.First(&currentUser, uid).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find user"})
-		return
-	}
-
-	// Read friend_id from the JSON body
 	var req struct {
-		FriendID uint `json:"friend_id" binding:"required"`
+		UserID   string `json:"user_id"`
+		FriendID uint   `json:"friend_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	// Now let's find that friend user by DB ID
-	var friendUser db.User
-	if err := db.DB.Where("id = ?", req.FriendID).First(&friendUser).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Friend user not found"})
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
 		return
 	}
-
-	// They must already be in the Friend table or else not friends.
-	var friendship db.Friend
-	err := db.DB.Where("user_id = ? AND friend_id = ?", currentUser.UserID, friendUser.UserID).First(&friendship).Error
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "You are not friends with this user"})
+	friendship, ok := loadPinTarget(c, currentUser.UserID, req.UserID, req.FriendID)
+	if !ok {
 		return
 	}
 
-	// If already pinned, do nothing or return conflict
+	// If already pinned, return conflict
 	if friendship.IsPinned {
 		c.JSON(http.StatusConflict, gin.H{"error": "Friend is already pinned"})
 		return
 	}
 
-	// Otherwise, update IsPinned
-	friendship.IsPinned = true
-	if err := db.DB.Save(&friendship).Error; err != nil {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var maxPosition *int
+		if err := tx.Model(&db.Friend{}).
+			Where("user_id = ? AND is_pinned = ?", currentUser.UserID, true).
+			Select("MAX(pin_position)").Scan(&maxPosition).Error; err != nil {
+			return err
+		}
+		friendship.IsPinned = true
+		friendship.PinPosition = 0
+		if maxPosition != nil {
+			friendship.PinPosition = *maxPosition + 1
+		}
+		return tx.Save(friendship).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not pin friend"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Friend pinned"})
+	c.JSON(http.StatusOK, gin.H{"message": "Friend pinned", "user_id": friendship.FriendID, "pin_position": friendship.PinPosition})
 }
 
-// UnpinFriend sets is_pinned = false on the existing friendship row
-// request body: { "friend_id": 123 }
+// UnpinFriend removes a friend from the pinned list
+// request body: { "user_id": "UUID" } or the legacy { "friend_id": 123 }
 func UnpinFriend(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	uid := userID.(uint)
-
-	var currentUser db.User
-	if err := db.DB.First(&currentUser, uid).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find user"})
-		return
-	}
-
 	var req struct {
-		FriendID uint `json:"friend_id" binding:"required"`
+		UserID   string `json:"user_id"`
+		FriendID uint   `json:"friend_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	var friendUser db.User
-	if err := db.DB.Where("id = ?", req.FriendID).First(&friendUser).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Friend user not found"})
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
 		return
 	}
-
-	var friendship db.Friend
-	err := db.DB.Where("user_id = ? AND friend_id = ?", currentUser.UserID, friendUser.UserID).First(&friendship).Error
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Friend is not pinned or not a friend"})
+	friendship, ok := loadPinTarget(c, currentUser.UserID, req.UserID, req.FriendID)
+	if !ok {
 		return
 	}
 
-	// If not pinned, return conflict or do nothing
+	// If not pinned, return conflict
 	if !friendship.IsPinned {
 		c.JSON(http.StatusConflict, gin.H{"error": "Friend is not pinned"})
 		return
 	}
 
 	friendship.IsPinned = false
-	if err := db.DB.Save(&friendship).Error; err != nil {
+	friendship.PinPosition = 0
+	if err := db.DB.Save(friendship).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not unpin friend"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Friend unpinned"})
+	c.JSON(http.StatusOK, gin.H{"message": "Friend unpinned", "user_id": friendship.FriendID})
 }
 
-// GetPinnedFriends returns a list of pinned friendships
+// GetPinnedFriends returns the pinned friends in pin order
 func GetPinnedFriends(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
 		return
 	}
-	uid := userID.(uint)
 
-	var currentUser db.User
-	if err := db.DB.First(&currentUser, uid).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find user"})
-		return
-	}
-
-	// Get all pinned friends for the current user
-	var pinnedFriends []db.Friend
-	if err := db.DB.Where("user_id = ? AND is_pinned = ?", currentUser.UserID, true).Find(&pinnedFriends).Error; err != nil {
+	pinned, err := queryFriendList(currentUser.UserID, true, 0)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch pinned friends"})
 		return
 	}
 
-	var result []FriendUser
-	for _, pf := range pinnedFriends {
-		var friendUser db.User
-		if err := db.DB.Where("user_id = ?", pf.FriendID).First(&friendUser).Error; err == nil {
-			result = append(result, FriendUser{
-				ID:        pf.ID,
-				UserID:    pf.FriendID,
-				Username:  friendUser.Username,
-				IsPinned:  pf.IsPinned,
-				CreatedAt: pf.CreatedAt,
-			})
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"pinned_friends": result})
+	c.JSON(http.StatusOK, gin.H{"pinned_friends": pinned})
 }
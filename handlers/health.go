@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+	"GoCall_api/metrics"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build metadata, overridden at link time:
+// go build -ldflags "-X GoCall_api/handlers.Version=1.2.3 -X GoCall_api/handlers.Commit=abc123"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startedAt = time.Now()
+
+const readinessTimeout = 2 * time.Second
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type adminStatusResponse struct {
+	Version           string            `json:"version"`
+	Commit            string            `json:"commit"`
+	StartedAt         time.Time         `json:"started_at"`
+	Uptime            string            `json:"uptime"`
+	ChatConnections   int               `json:"chat_connections"`
+	VoiceParticipants int64             `json:"voice_participants"`
+	VoiceRooms        int64             `json:"voice_rooms"`
+	Ready             bool              `json:"ready"`
+	Checks            map[string]string `json:"checks"`
+}
+
+// PingPong returns a lightweight healthcheck response.
+func PingPong(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "pong"})
+}
+
+// Healthz reports liveness: the process is up and serving HTTP.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports readiness: the database answers, the schema is migrated and LiveKit
+// credentials and URL are configured.
+func Readyz(c *gin.Context) {
+	ready, checks := runReadinessChecks(c.Request.Context())
+
+	status := http.StatusOK
+	response := readinessResponse{Status: "ok", Checks: checks}
+	if !ready {
+		status = http.StatusServiceUnavailable
+		response.Status = "unavailable"
+		utils.Log(c).Warn("readiness check failed", "checks", checks)
+	}
+
+	c.JSON(status, response)
+}
+
+// GetAdminStatus returns build, uptime and connection details for server administrators.
+func GetAdminStatus(c *gin.Context) {
+	ready, checks := runReadinessChecks(c.Request.Context())
+
+	var voiceParticipants, voiceRooms int64
+	if err := db.DB.Model(&db.RoomVoiceParticipant{}).Count(&voiceParticipants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count voice participants"})
+		return
+	}
+	if err := db.DB.Model(&db.RoomVoiceParticipant{}).Distinct("room_id").Count(&voiceRooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count voice rooms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, adminStatusResponse{
+		Version:           Version,
+		Commit:            buildCommit(),
+		StartedAt:         startedAt,
+		Uptime:            time.Since(startedAt).Round(time.Second).String(),
+		ChatConnections:   int(metrics.GaugeValue(metrics.ChatConnections)), // те же сокеты, что и в gocall_chat_connections
+		VoiceParticipants: voiceParticipants,
+		VoiceRooms:        voiceRooms,
+		Ready:             ready,
+		Checks:            checks,
+	})
+}
+
+func runReadinessChecks(parent context.Context) (bool, map[string]string) {
+	ctx, cancel := context.WithTimeout(parent, readinessTimeout)
+	defer cancel()
+
+	ready := true
+	checks := map[string]string{}
+
+	if err := db.Ping(ctx); err != nil {
+		ready = false
+		checks["database"] = err.Error()
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if err := db.CheckMigrations(); err != nil {
+		ready = false
+		checks["migrations"] = err.Error()
+	} else {
+		checks["migrations"] = "ok"
+	}
+
+	// Без URL не работают ни токены для клиентов, ни RoomService
+	switch {
+	case !config.Current.LiveKitConfigured():
+		ready = false
+		checks["livekit"] = "LIVEKIT_API_KEY and LIVEKIT_API_SECRET are not set"
+	case config.Current.LiveKit.URL == "":
+		ready = false
+		checks["livekit"] = "LIVEKIT_URL is not set"
+	default:
+		checks["livekit"] = "ok"
+	}
+
+	return ready, checks
+}
+
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"GoCall_api/config"
+	"GoCall_api/metrics"
+)
+
+func TestReadyzRequiresLiveKitURL(t *testing.T) {
+	setupTestDB(t)
+
+	config.Current.LiveKit.URL = ""
+	w := callHandler(Readyz, 0, "")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "LIVEKIT_URL is not set") {
+		t.Fatalf("without URL: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	config.Current.LiveKit.URL = "ws://localhost:7880"
+	if w := callHandler(Readyz, 0, ""); w.Code != http.StatusOK {
+		t.Fatalf("with URL: status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminStatusCountsChatSockets(t *testing.T) {
+	setupTestDB(t)
+
+	// Два сокета одного пользователя: в chatClients одна запись, в метрике — два
+	metrics.ChatConnections.Add(2)
+	t.Cleanup(func() { metrics.ChatConnections.Sub(2) })
+
+	w := callHandler(GetAdminStatus, 0, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"chat_connections":2`) {
+		t.Fatalf("status = %d, body = %s, want chat_connections 2", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testRoomID        = "room-1"
+	testUserID        = "user-1"
+	testTargetID      = "user-2"
+	testLiveKitKey    = "test-key"
+	testLiveKitSecret = "test-secret-of-sufficient-length"
+)
+
+// setupTestDB points db.DB at a fresh in-memory database and configures test LiveKit credentials.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	// Меняем копию, чтобы тестовые ключи не остались в общей конфигурации
+	previous := config.Current
+	cfg := *config.Current
+	cfg.LiveKit.APIKey = testLiveKitKey
+	cfg.LiveKit.APISecret = testLiveKitSecret
+	config.Current = &cfg
+
+	db.InitDatabase("file:" + t.Name() + "?mode=memory&cache=shared")
+	t.Cleanup(func() {
+		db.Close()
+		config.Current = previous
+	})
+}
+
+// seedRoom creates the public test room owned by testUserID.
+func seedRoom(t *testing.T) {
+	t.Helper()
+	room := db.Room{UserID: testUserID, Name: "test", Type: "public"}
+	if err := db.DB.Create(&room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	// BeforeCreate всегда генерирует новый UUID
+	if err := db.DB.Model(&room).Update("room_id", testRoomID).Error; err != nil {
+		t.Fatalf("set room id: %v", err)
+	}
+}
+
+// seedMember adds userID to the test room with role, optionally already in voice with mic and camera on.
+func seedMember(t *testing.T, userID, role string, inVoice bool) {
+	t.Helper()
+	if err := db.DB.Create(&db.RoomMember{RoomID: testRoomID, UserID: userID, Role: role}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	if inVoice {
+		participant := db.RoomVoiceParticipant{RoomID: testRoomID, UserID: userID, IsMicEnabled: true, IsCameraEnabled: true}
+		if err := db.DB.Create(&participant).Error; err != nil {
+			t.Fatalf("create voice participant: %v", err)
+		}
+	}
+}
+
+// seedUser creates a user with the given UUID and returns their users.id.
+func seedUser(t *testing.T, userID, username string) uint {
+	t.Helper()
+	user := db.User{Username: username, PasswordHash: "x"}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	// BeforeCreate всегда генерирует новый UUID
+	if err := db.DB.Model(&user).Update("user_id", userID).Error; err != nil {
+		t.Fatalf("set user id: %v", err)
+	}
+	return user.ID
+}
+
+// callHandler runs handler for the test room as the user with users.id actorID.
+func callHandler(handler gin.HandlerFunc, actorID uint, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/rooms/"+testRoomID, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: testRoomID}}
+	c.Set("user_id", actorID)
+	handler(c)
+	return w
+}
+
+// voiceParticipant fetches userID's voice presence in the test room.
+func voiceParticipant(t *testing.T, userID string) (db.RoomVoiceParticipant, bool) {
+	t.Helper()
+	var participant db.RoomVoiceParticipant
+	result := db.DB.Where("room_id = ? AND user_id = ?", testRoomID, userID).Limit(1).Find(&participant)
+	if result.Error != nil {
+		t.Fatalf("fetch voice participant: %v", result.Error)
+	}
+	return participant, result.RowsAffected > 0
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LiveKit webhook event names handled by HandleLiveKitWebhook.
+const (
+	livekitEventParticipantJoined = "participant_joined"
+	livekitEventParticipantLeft   = "participant_left"
+	livekitEventTrackPublished    = "track_published"
+	livekitEventTrackUnpublished  = "track_unpublished"
+	livekitEventRoomFinished      = "room_finished"
+)
+
+// HandleLiveKitWebhook verifies a signed LiveKit webhook and reconciles
+// room voice presence and media flags with what LiveKit reports.
+// LiveKit rooms are named after Room.RoomID and participants after User.UserID.
+func HandleLiveKitWebhook(c *gin.Context) {
+	if !config.Current.LiveKitConfigured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LiveKit is not configured"})
+		return
+	}
+
+	provider := auth.NewSimpleKeyProvider(config.Current.LiveKit.APIKey, config.Current.LiveKit.APISecret)
+	event, err := webhook.ReceiveWebhookEvent(c.Request, provider)
+	if err != nil {
+		utils.Log(c).Warn("rejected livekit webhook", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	roomID := event.GetRoom().GetName()
+	userID := event.GetParticipant().GetIdentity()
+	utils.SetLogRoom(c, roomID)
+	logger := utils.Log(c).With("event", event.GetEvent(), "participant", userID)
+
+	switch event.GetEvent() {
+	case livekitEventParticipantJoined:
+		err = reconcileVoiceJoined(roomID, userID)
+	case livekitEventParticipantLeft:
+		err = reconcileVoiceLeft(roomID, userID)
+	case livekitEventTrackPublished:
+		err = reconcileVoiceTrack(roomID, userID, event.GetTrack().GetSource(), true)
+	case livekitEventTrackUnpublished:
+		err = reconcileVoiceTrack(roomID, userID, event.GetTrack().GetSource(), false)
+	case livekitEventRoomFinished:
+		err = reconcileRoomFinished(roomID)
+	default:
+		logger.Debug("ignored livekit webhook")
+		c.JSON(http.StatusOK, gin.H{"message": "Ignored"})
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to reconcile livekit webhook", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile voice presence"})
+		return
+	}
+
+	logger.Debug("reconciled livekit webhook")
+	c.JSON(http.StatusOK, gin.H{"message": "Processed"})
+}
+
+// reconcileVoiceJoined records voice presence for a participant LiveKit saw join,
+// as long as the user is still a member of the room.
+func reconcileVoiceJoined(roomID, userID string) error {
+	var member db.RoomMember
+	if err := db.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	voiceParticipant := db.RoomVoiceParticipant{
+		RoomID: roomID,
+		UserID: userID,
+	}
+	result := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&voiceParticipant)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		publishRoomEvent(roomID, roomEventVoiceJoined, voiceEventData(&voiceParticipant))
+	}
+	return nil
+}
+
+// reconcileVoiceLeft drops voice presence for a participant LiveKit saw leave.
+func reconcileVoiceLeft(roomID, userID string) error {
+	result := db.DB.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&db.RoomVoiceParticipant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		publishRoomEvent(roomID, roomEventVoiceLeft, gin.H{"user_id": userID, "reason": "disconnected"})
+	}
+	return nil
+}
+
+// reconcileRoomFinished clears voice presence once LiveKit closes the room.
+func reconcileRoomFinished(roomID string) error {
+	var participants []db.RoomVoiceParticipant
+	if err := db.DB.Where("room_id = ?", roomID).Find(&participants).Error; err != nil {
+		return err
+	}
+	if err := db.DB.Where("room_id = ?", roomID).Delete(&db.RoomVoiceParticipant{}).Error; err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		publishRoomEvent(roomID, roomEventVoiceLeft, gin.H{"user_id": participant.UserID, "reason": "room_finished"})
+	}
+	return nil
+}
+
+// reconcileVoiceTrack mirrors a published or unpublished track onto the participant's media flags.
+func reconcileVoiceTrack(roomID, userID string, source livekit.TrackSource, enabled bool) error {
+	var column string
+	switch source {
+	case livekit.TrackSource_MICROPHONE:
+		column = "is_mic_enabled"
+	case livekit.TrackSource_CAMERA:
+		column = "is_camera_enabled"
+	case livekit.TrackSource_SCREEN_SHARE:
+		column = "is_screen_sharing"
+	default:
+		return nil
+	}
+
+	result := db.DB.Model(&db.RoomVoiceParticipant{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Update(column, enabled)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	var voiceParticipant db.RoomVoiceParticipant
+	if err := db.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&voiceParticipant).Error; err != nil {
+		return err
+	}
+	publishRoomEvent(roomID, roomEventMediaChanged, voiceEventData(&voiceParticipant))
+	return nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livekit/protocol/auth"
+)
+
+// sendWebhook posts body to the webhook handler, signed with secret.
+func sendWebhook(t *testing.T, body, secret string) *httptest.ResponseRecorder {
+	t.Helper()
+	sum := sha256.Sum256([]byte(body))
+	token, err := auth.NewAccessToken(testLiveKitKey, secret).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		t.Fatalf("sign webhook: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/livekit/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/webhook+json")
+	req.Header.Set("Authorization", token)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	HandleLiveKitWebhook(c)
+	return w
+}
+
+func participantEvent(event string) string {
+	return `{"event":"` + event + `","room":{"name":"` + testRoomID + `"},"participant":{"identity":"` + testUserID + `"}}`
+}
+
+func trackEvent(event, source string) string {
+	return `{"event":"` + event + `","room":{"name":"` + testRoomID + `"},"participant":{"identity":"` + testUserID + `"},"track":{"source":"` + source + `"}}`
+}
+
+func TestLiveKitWebhookRejectsBadSignature(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	seedMember(t, testUserID, roomRoleMember, false)
+
+	w := sendWebhook(t, participantEvent(livekitEventParticipantJoined), "some-other-secret-of-enough-length")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if _, found := voiceParticipant(t, testUserID); found {
+		t.Fatal("voice participant recorded from an unsigned webhook")
+	}
+}
+
+func TestLiveKitWebhookParticipantJoinedAndLeft(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	seedMember(t, testUserID, roomRoleMember, false)
+
+	if w := sendWebhook(t, participantEvent(livekitEventParticipantJoined), testLiveKitSecret); w.Code != http.StatusOK {
+		t.Fatalf("joined: status = %d, body = %s", w.Code, w.Body)
+	}
+	if _, found := voiceParticipant(t, testUserID); !found {
+		t.Fatal("joined: voice participant not recorded")
+	}
+
+	if w := sendWebhook(t, participantEvent(livekitEventParticipantLeft), testLiveKitSecret); w.Code != http.StatusOK {
+		t.Fatalf("left: status = %d, body = %s", w.Code, w.Body)
+	}
+	if _, found := voiceParticipant(t, testUserID); found {
+		t.Fatal("left: voice participant not removed")
+	}
+}
+
+func TestLiveKitWebhookIgnoresNonMembers(t *testing.T) {
+	setupTestDB(t)
+
+	if w := sendWebhook(t, participantEvent(livekitEventParticipantJoined), testLiveKitSecret); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if _, found := voiceParticipant(t, testUserID); found {
+		t.Fatal("voice participant recorded for a user who is not a member")
+	}
+}
+
+func TestLiveKitWebhookTrackFlags(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	seedMember(t, testUserID, roomRoleMember, true)
+
+	steps := []struct {
+		event, source       string
+		mic, camera, screen bool
+	}{
+		{livekitEventTrackUnpublished, "MICROPHONE", false, true, false},
+		{livekitEventTrackUnpublished, "CAMERA", false, false, false},
+		{livekitEventTrackPublished, "SCREEN_SHARE", false, false, true},
+		{livekitEventTrackPublished, "MICROPHONE", true, false, true},
+		{livekitEventTrackUnpublished, "SCREEN_SHARE", true, false, false},
+	}
+	for _, step := range steps {
+		if w := sendWebhook(t, trackEvent(step.event, step.source), testLiveKitSecret); w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status = %d, body = %s", step.event, step.source, w.Code, w.Body)
+		}
+		participant, found := voiceParticipant(t, testUserID)
+		if !found {
+			t.Fatalf("%s %s: voice participant missing", step.event, step.source)
+		}
+		if participant.IsMicEnabled != step.mic || participant.IsCameraEnabled != step.camera || participant.IsScreenSharing != step.screen {
+			t.Fatalf("%s %s: flags mic=%v camera=%v screen=%v, want mic=%v camera=%v screen=%v",
+				step.event, step.source,
+				participant.IsMicEnabled, participant.IsCameraEnabled, participant.IsScreenSharing,
+				step.mic, step.camera, step.screen)
+		}
+	}
+}
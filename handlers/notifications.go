@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notification types.
+const (
+	notificationFriendRequest  = "friend_request"
+	notificationFriendAccepted = "friend_accepted"
+	notificationRoomInvite     = "room_invite"
+	notificationRoomRole       = "room_role_changed"
+	notificationMessage        = "message"
+)
+
+const (
+	notificationPageSize    = 50
+	notificationPreviewSize = 140
+)
+
+// unreadNotificationCount counts the user's unread notifications.
+func unreadNotificationCount(userID string) (int64, error) {
+	var count int64
+	err := db.DB.Model(&db.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// pushNotification sends the notification and the new unread count to the recipient's socket.
+func pushNotification(n *db.Notification) {
+	unread, err := unreadNotificationCount(n.UserID)
+	if err != nil {
+		utils.Logger.Warn("failed to count unread notifications", "user_id", n.UserID, "error", err)
+		return
+	}
+	sendToUser(n.UserID, gin.H{"type": "notification", "notification": n, "unread_count": unread})
+}
+
+// notify stores a notification and pushes it live. Failures are logged, never
+// surfaced: the action that caused the notification has already succeeded.
+func notify(n db.Notification) {
+	if err := db.DB.Create(&n).Error; err != nil {
+		utils.Logger.Error("failed to store notification", "user_id", n.UserID, "type", n.Type, "error", err)
+		return
+	}
+	pushNotification(&n)
+}
+
+// resolveNotification marks the recipient's notification about a friend request
+// or room invite as read once it has been answered.
+func resolveNotification(userID, notificationType string, refID uint) {
+	if err := db.DB.Model(&db.Notification{}).
+		Where("user_id = ? AND type = ? AND ref_id = ? AND read_at IS NULL", userID, notificationType, refID).
+		Update("read_at", time.Now()).Error; err != nil {
+		utils.Logger.Warn("failed to resolve notification", "user_id", userID, "type", notificationType, "error", err)
+	}
+}
+
+// retractNotification deletes a notification whose subject was withdrawn.
+func retractNotification(userID, notificationType string, refID uint) {
+	if err := db.DB.Where("user_id = ? AND type = ? AND ref_id = ?", userID, notificationType, refID).
+		Delete(&db.Notification{}).Error; err != nil {
+		utils.Logger.Warn("failed to retract notification", "user_id", userID, "type", notificationType, "error", err)
+	}
+}
+
+// messagePreview shortens a chat message for notifications and friend-list previews.
+func messagePreview(text string) string {
+	if utf8.RuneCountInString(text) > notificationPreviewSize {
+		return string([]rune(text)[:notificationPreviewSize]) + "…"
+	}
+	return text
+}
+
+// notifyMessage records a chat message for a recipient who does not have the
+// conversation open. An unread message notification from the same sender is
+// replaced by a new one carrying the running count, so it moves to the top.
+func notifyMessage(recipientID, senderID, text string) {
+	n := db.Notification{UserID: recipientID, Type: notificationMessage, ActorID: senderID, Preview: messagePreview(text), Count: 1}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var existing db.Notification
+		err := tx.Where("user_id = ? AND type = ? AND actor_id = ? AND read_at IS NULL", recipientID, notificationMessage, senderID).
+			First(&existing).Error
+		if err == nil {
+			n.Count = existing.Count + 1
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(&n).Error
+	})
+	if err != nil {
+		utils.Logger.Error("failed to store message notification", "user_id", recipientID, "error", err)
+		return
+	}
+	pushNotification(&n)
+}
+
+// GetNotifications lists the caller's notifications, newest first.
+// Query: unread=true to skip read ones, before=<id> for the next page.
+func GetNotifications(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	query := db.DB.Where("user_id = ?", currentUser.UserID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if before := c.Query("before"); before != "" {
+		beforeID, err := strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'before' parameter"})
+			return
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	notifications := make([]db.Notification, 0)
+	if err := query.Order("id DESC").Limit(notificationPageSize).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := unreadNotificationCount(currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	var nextBefore uint
+	if len(notifications) == notificationPageSize {
+		nextBefore = notifications[len(notifications)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread_count": unread, "next_before": nextBefore})
+}
+
+// MarkNotificationsRead marks the given notifications, or all of them, as read.
+func MarkNotificationsRead(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+		All bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (len(req.IDs) == 0 && !req.All) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. Provide 'ids' or 'all'"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	query := db.DB.Model(&db.Notification{}).Where("user_id = ? AND read_at IS NULL", currentUser.UserID)
+	if !req.All {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	result := query.Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications read"})
+		return
+	}
+
+	unread, err := unreadNotificationCount(currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": result.RowsAffected, "unread_count": unread})
+}
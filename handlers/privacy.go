@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Privacy audiences: who a setting lets through.
+const (
+	audienceEveryone         = "everyone"
+	audienceFriendsOfFriends = "friends_of_friends"
+	audienceFriends          = "friends"
+	audienceNobody           = "nobody"
+)
+
+// Audiences accepted by each privacy setting.
+var (
+	discoverabilityAudiences = []string{audienceEveryone, audienceFriendsOfFriends, audienceNobody}
+	friendRequestAudiences   = []string{audienceEveryone, audienceFriendsOfFriends, audienceNobody}
+	roomInviteAudiences      = []string{audienceEveryone, audienceFriends, audienceNobody}
+	onlineStatusAudiences    = []string{audienceEveryone, audienceFriends, audienceNobody}
+)
+
+// loadUserPrivacy returns the user's privacy settings, or the defaults when none are stored.
+func loadUserPrivacy(userID string) (db.UserPrivacy, error) {
+	privacy := db.UserPrivacy{
+		UserID:          userID,
+		Discoverability: audienceEveryone,
+		FriendRequests:  audienceEveryone,
+		RoomInvites:     audienceEveryone,
+		OnlineStatus:    audienceEveryone,
+	}
+	err := db.DB.Where("user_id = ?", userID).First(&privacy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return privacy, err
+	}
+	return privacy, nil
+}
+
+// shareFriend reports whether the two users have at least one friend in common.
+func shareFriend(userA, userB string) (bool, error) {
+	var count int64
+	err := db.DB.Table("friends a").
+		Joins("JOIN friends b ON b.friend_id = a.friend_id AND b.user_id = ?", userB).
+		Where("a.user_id = ?", userA).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// audienceAllows reports whether viewerID belongs to ownerID's audience for one
+// of their privacy settings. Users always pass their own settings.
+func audienceAllows(audience, viewerID, ownerID string) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+	switch audience {
+	case audienceEveryone, "":
+		return true, nil
+	case audienceNobody:
+		return false, nil
+	}
+
+	friends, err := areFriends(viewerID, ownerID)
+	if err != nil || friends {
+		return friends, err
+	}
+	if audience == audienceFriendsOfFriends {
+		return shareFriend(viewerID, ownerID)
+	}
+	return false, nil
+}
+
+// canFindUser applies the owner's discoverability to the viewer. Friends can
+// always find each other, whatever the setting.
+func canFindUser(viewerID, ownerID string) (bool, error) {
+	privacy, err := loadUserPrivacy(ownerID)
+	if err != nil {
+		return false, err
+	}
+	if privacy.Discoverability == audienceNobody {
+		return areFriends(viewerID, ownerID)
+	}
+	return audienceAllows(privacy.Discoverability, viewerID, ownerID)
+}
+
+// canViewProfile reports whether the viewer may look the owner up by UUID: they can
+// find the owner, or already know them through a friend request, a block they
+// placed, or a room they are both members of.
+func canViewProfile(viewerID, ownerID string) (bool, error) {
+	if found, err := canFindUser(viewerID, ownerID); err != nil || found {
+		return found, err
+	}
+
+	var count int64
+	if err := db.DB.Model(&db.FriendRequest{}).
+		Where("status = 'pending' AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))", viewerID, ownerID, ownerID, viewerID).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	if err := db.DB.Model(&db.UserBlock{}).Where("blocker_id = ? AND blocked_id = ?", viewerID, ownerID).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	err := db.DB.Table("room_members mine").
+		Joins("JOIN room_members theirs ON theirs.room_id = mine.room_id AND theirs.user_id = ?", ownerID).
+		Where("mine.user_id = ?", viewerID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// findVisibleUser fetches the user matching the condition for the viewer. It reports
+// gorm.ErrRecordNotFound both when no such user exists and when canViewProfile hides
+// them, so callers answer the same 404 and do not reveal the account.
+func findVisibleUser(viewerID string, query string, args ...interface{}) (*db.User, error) {
+	var user db.User
+	if err := db.DB.Where(query, args...).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	visible, err := canViewProfile(viewerID, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// loadVisibleUser fetches the user identified by uuid for the viewer, answering 404
+// both when the user does not exist and when canViewProfile hides them.
+func loadVisibleUser(c *gin.Context, viewerID, uuid string) (*db.User, bool) {
+	user, err := findVisibleUser(viewerID, "user_id = ?", uuid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// discoverableTo limits a query over the users table to the users viewerID may find.
+func discoverableTo(viewerID string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where(`(users.user_id NOT IN (SELECT user_id FROM user_privacies WHERE discoverability <> ?)
+			OR users.user_id IN (SELECT friend_id FROM friends WHERE user_id = ?)
+			OR (users.user_id IN (SELECT user_id FROM user_privacies WHERE discoverability = ?)
+				AND users.user_id IN (SELECT theirs.friend_id FROM friends mine JOIN friends theirs ON theirs.user_id = mine.friend_id WHERE mine.user_id = ?)))`,
+			audienceEveryone, viewerID, audienceFriendsOfFriends, viewerID)
+	}
+}
+
+// hiddenOnlineStatus returns the users among userIDs whose online status viewerID may not see.
+func hiddenOnlineStatus(viewerID string, userIDs []string) (map[string]bool, error) {
+	hidden := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return hidden, nil
+	}
+
+	var privacies []db.UserPrivacy
+	if err := db.DB.Where("user_id IN ? AND user_id <> ? AND online_status <> ?", userIDs, viewerID, audienceEveryone).
+		Find(&privacies).Error; err != nil {
+		return nil, err
+	}
+	for _, privacy := range privacies {
+		visible, err := audienceAllows(privacy.OnlineStatus, viewerID, privacy.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			hidden[privacy.UserID] = true
+		}
+	}
+	return hidden, nil
+}
+
+// validAudience reports whether value is one of the allowed audiences.
+func validAudience(value string, allowed []string) bool {
+	for _, audience := range allowed {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrivacySettings returns the authenticated user's privacy settings.
+func GetPrivacySettings(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	privacy, err := loadUserPrivacy(currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"privacy": privacy})
+}
+
+// UpdatePrivacySettings changes the authenticated user's privacy settings. Omitted fields are kept.
+func UpdatePrivacySettings(c *gin.Context) {
+	var req struct {
+		AllowDirectAdd      *bool   `json:"allow_direct_add"`
+		HideFromSuggestions *bool   `json:"hide_from_suggestions"`
+		HideMutualFriends   *bool   `json:"hide_mutual_friends"`
+		AllowEmailLookup    *bool   `json:"allow_email_lookup"`
+		Discoverability     *string `json:"discoverability"`
+		FriendRequests      *string `json:"friend_requests"`
+		RoomInvites         *string `json:"room_invites"`
+		OnlineStatus        *string `json:"online_status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	audiences := []struct {
+		name    string
+		value   *string
+		allowed []string
+	}{
+		{"discoverability", req.Discoverability, discoverabilityAudiences},
+		{"friend_requests", req.FriendRequests, friendRequestAudiences},
+		{"room_invites", req.RoomInvites, roomInviteAudiences},
+		{"online_status", req.OnlineStatus, onlineStatusAudiences},
+	}
+	for _, audience := range audiences {
+		if audience.value != nil && !validAudience(*audience.value, audience.allowed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid '" + audience.name + "' value", "allowed": audience.allowed})
+			return
+		}
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	privacy, err := loadUserPrivacy(currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if req.AllowDirectAdd != nil {
+		privacy.AllowDirectAdd = *req.AllowDirectAdd
+	}
+	if req.HideFromSuggestions != nil {
+		privacy.HideFromSuggestions = *req.HideFromSuggestions
+	}
+	if req.HideMutualFriends != nil {
+		privacy.HideMutualFriends = *req.HideMutualFriends
+	}
+	if req.AllowEmailLookup != nil {
+		privacy.AllowEmailLookup = *req.AllowEmailLookup
+	}
+	if req.Discoverability != nil {
+		privacy.Discoverability = *req.Discoverability
+	}
+	if req.FriendRequests != nil {
+		privacy.FriendRequests = *req.FriendRequests
+	}
+	if req.RoomInvites != nil {
+		privacy.RoomInvites = *req.RoomInvites
+	}
+	if req.OnlineStatus != nil {
+		privacy.OnlineStatus = *req.OnlineStatus
+	}
+
+	if err := db.DB.Save(&privacy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"privacy": privacy})
+}
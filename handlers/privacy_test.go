@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"GoCall_api/db"
+)
+
+// hideUser sets testTargetID's discoverability to nobody while still accepting
+// friend requests and room invites from everyone.
+func hideUser(t *testing.T) {
+	t.Helper()
+	privacy := db.UserPrivacy{
+		UserID:          testTargetID,
+		Discoverability: audienceNobody,
+		FriendRequests:  audienceEveryone,
+		RoomInvites:     audienceEveryone,
+		OnlineStatus:    audienceEveryone,
+	}
+	if err := db.DB.Create(&privacy).Error; err != nil {
+		t.Fatalf("create privacy: %v", err)
+	}
+}
+
+func TestRequestFriendHidesUndiscoverableUser(t *testing.T) {
+	setupTestDB(t)
+	senderID := seedUser(t, testUserID, "alice")
+	seedUser(t, testTargetID, "bob")
+	hideUser(t)
+
+	hidden := callHandler(RequestFriend, senderID, `{"to_username":"bob"}`)
+	missing := callHandler(RequestFriend, senderID, `{"to_username":"nobody"}`)
+	if hidden.Code != http.StatusNotFound || hidden.Body.String() != missing.Body.String() {
+		t.Fatalf("hidden user: %d %s, unknown user: %d %s; want identical 404s", hidden.Code, hidden.Body, missing.Code, missing.Body)
+	}
+	var requests int64
+	db.DB.Model(&db.FriendRequest{}).Count(&requests)
+	if requests != 0 {
+		t.Fatal("friend request sent to an undiscoverable user")
+	}
+}
+
+func TestInviteUserToRoomHidesUndiscoverableUser(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	adminID := seedUser(t, testUserID, "alice")
+	seedMember(t, testUserID, roomRoleCreator, false)
+	seedUser(t, testTargetID, "bob")
+	hideUser(t)
+
+	hidden := callHandler(InviteUserToRoom, adminID, `{"roomID":"`+testRoomID+`","username":"bob"}`)
+	missing := callHandler(InviteUserToRoom, adminID, `{"roomID":"`+testRoomID+`","username":"nobody"}`)
+	if hidden.Code != http.StatusNotFound || hidden.Body.String() != missing.Body.String() {
+		t.Fatalf("hidden user: %d %s, unknown user: %d %s; want identical 404s", hidden.Code, hidden.Body, missing.Code, missing.Body)
+	}
+	var invites int64
+	db.DB.Model(&db.RoomInvite{}).Count(&invites)
+	if invites != 0 {
+		t.Fatal("room invite sent to an undiscoverable user")
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/config"
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Relationship states between the caller and another user.
+const (
+	relationNone     = "none"
+	relationOutgoing = "outgoing" // the caller sent a pending friend request
+	relationIncoming = "incoming" // the other user sent a pending friend request
+	relationFriends  = "friends"
+	relationBlocked  = "blocked" // either user blocked the other
+)
+
+// relationship is the caller's view of their relation to another user.
+type relationship struct {
+	UserID      string `json:"user_id"`
+	State       string `json:"state"`
+	RequestID   uint   `json:"request_id,omitempty"`
+	BlockedByMe bool   `json:"blocked_by_me,omitempty"`
+}
+
+// relationshipBetween resolves the relationship state from viewerID to otherID.
+// Blocks take precedence over friendship, friendship over pending requests.
+// Requests older than friends.request_ttl no longer count as pending.
+func relationshipBetween(tx *gorm.DB, viewerID, otherID string) (relationship, error) {
+	rel := relationship{UserID: otherID, State: relationNone}
+
+	var blocks []db.UserBlock
+	if err := tx.Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", viewerID, otherID, otherID, viewerID).
+		Find(&blocks).Error; err != nil {
+		return rel, err
+	}
+	if len(blocks) > 0 {
+		rel.State = relationBlocked
+		for _, block := range blocks {
+			if block.BlockerID == viewerID {
+				rel.BlockedByMe = true
+			}
+		}
+		return rel, nil
+	}
+
+	var friendCount int64
+	if err := tx.Model(&db.Friend{}).Where("user_id = ? AND friend_id = ?", viewerID, otherID).Count(&friendCount).Error; err != nil {
+		return rel, err
+	}
+	if friendCount > 0 {
+		rel.State = relationFriends
+		return rel, nil
+	}
+
+	var request db.FriendRequest
+	err := tx.Where("status = 'pending' AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))", viewerID, otherID, otherID, viewerID).
+		Where("created_at > ?", time.Now().Add(-config.Current.Friends.RequestTTL.Duration)).
+		Order("created_at DESC").
+		First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rel, nil
+	}
+	if err != nil {
+		return rel, err
+	}
+	rel.RequestID = request.ID
+	if request.FromUserID == viewerID {
+		rel.State = relationOutgoing
+	} else {
+		rel.State = relationIncoming
+	}
+	return rel, nil
+}
+
+// relationshipsFor resolves relationshipBetween for many users at once, with the
+// same precedence, in three queries instead of three per user.
+func relationshipsFor(tx *gorm.DB, viewerID string, otherIDs []string) (map[string]relationship, error) {
+	rels := make(map[string]relationship, len(otherIDs))
+	if len(otherIDs) == 0 {
+		return rels, nil
+	}
+	for _, id := range otherIDs {
+		rels[id] = relationship{UserID: id, State: relationNone}
+	}
+
+	var requests []db.FriendRequest
+	if err := tx.Where("status = 'pending' AND ((from_user_id = ? AND to_user_id IN ?) OR (to_user_id = ? AND from_user_id IN ?))", viewerID, otherIDs, viewerID, otherIDs).
+		Where("created_at > ?", time.Now().Add(-config.Current.Friends.RequestTTL.Duration)).
+		Order("created_at ASC").
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		rel := relationship{RequestID: request.ID}
+		if request.FromUserID == viewerID {
+			rel.UserID, rel.State = request.ToUserID, relationOutgoing
+		} else {
+			rel.UserID, rel.State = request.FromUserID, relationIncoming
+		}
+		rels[rel.UserID] = rel
+	}
+
+	var friendIDs []string
+	if err := tx.Model(&db.Friend{}).Where("user_id = ? AND friend_id IN ?", viewerID, otherIDs).Pluck("friend_id", &friendIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range friendIDs {
+		rels[id] = relationship{UserID: id, State: relationFriends}
+	}
+
+	var blocks []db.UserBlock
+	if err := tx.Where("(blocker_id = ? AND blocked_id IN ?) OR (blocked_id = ? AND blocker_id IN ?)", viewerID, otherIDs, viewerID, otherIDs).
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		other := block.BlockerID
+		if other == viewerID {
+			other = block.BlockedID
+		}
+		rel := rels[other]
+		if rel.State != relationBlocked {
+			rel = relationship{UserID: other, State: relationBlocked}
+		}
+		if block.BlockerID == viewerID {
+			rel.BlockedByMe = true
+		}
+		rels[other] = rel
+	}
+	return rels, nil
+}
+
+// createFriendship stores both friendship rows, skipping any that already exist,
+// and marks pending requests between the pair as accepted.
+func createFriendship(tx *gorm.DB, userA, userB string) error {
+	for _, pair := range [][2]string{{userA, userB}, {userB, userA}} {
+		var count int64
+		if err := tx.Model(&db.Friend{}).Where("user_id = ? AND friend_id = ?", pair[0], pair[1]).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := tx.Create(&db.Friend{UserID: pair[0], FriendID: pair[1]}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&db.FriendRequest{}).
+		Where("status = 'pending' AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))", userA, userB, userB, userA).
+		Updates(map[string]interface{}{"status": "accepted", "responded_at": time.Now()}).Error
+}
+
+// GetRelationship returns the caller's relationship state with the user identified by :uuid.
+func GetRelationship(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	other, ok := loadVisibleUser(c, currentUser.UserID, c.Param("uuid"))
+	if !ok {
+		return
+	}
+	if other.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot query a relationship with yourself"})
+		return
+	}
+
+	rel, err := relationshipBetween(db.DB, currentUser.UserID, other.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch relationship"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"relationship": rel})
+}
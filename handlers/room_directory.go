@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Directory paging and room metadata limits.
+const (
+	directoryDefaultLimit = 20
+	directoryMaxLimit     = 50
+	maxRoomTags           = 5
+)
+
+var roomTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,19}$`)
+
+// Directory sort orders.
+const (
+	directorySortRecent  = "recent"
+	directorySortMembers = "members"
+	directorySortVoice   = "voice"
+)
+
+// roomDirectoryEntry is a public room as listed in the directory. It never carries the password.
+type roomDirectoryEntry struct {
+	ID          uint      `json:"id"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+	HasPassword bool      `json:"has_password"`
+	MemberCount int64     `json:"member_count"`
+	VoiceCount  int64     `json:"voice_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type roomDirectoryRow struct {
+	ID          uint
+	RoomID      string
+	UserID      string
+	Name        string
+	Description string
+	Tags        string
+	Password    string
+	MemberCount int64
+	VoiceCount  int64
+	CreatedAt   time.Time
+}
+
+// directoryCursor is the keyset position after the last returned room.
+type directoryCursor struct {
+	Value int64 `json:"v"`
+	ID    uint  `json:"id"`
+}
+
+func encodeDirectoryCursor(cursor directoryCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeDirectoryCursor(value string) (*directoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor directoryCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// normalizeRoomTags lowercases, trims and de-duplicates tags and validates them.
+func normalizeRoomTags(tags []string) (string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !roomTagPattern.MatchString(tag) {
+			return "", errors.New("Tags may only contain letters, digits and dashes (up to 20 characters)")
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxRoomTags {
+		return "", errors.New("Too many tags")
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func splitRoomTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+// excludeDirectRooms filters out one-to-one rooms.
+func excludeDirectRooms(query *gorm.DB) *gorm.DB {
+	return query.Where("kind <> ?", db.RoomKindDirect)
+}
+
+// GetRoomDirectory lists public rooms with search, tag filter, sorting and cursor pagination.
+// Query: q, tag, sort=recent|members|voice, limit, cursor.
+func GetRoomDirectory(c *gin.Context) {
+	sortBy := c.DefaultQuery("sort", directorySortRecent)
+	var sortColumn string
+	switch sortBy {
+	case directorySortRecent:
+		sortColumn = "id"
+	case directorySortMembers:
+		sortColumn = "member_count"
+	case directorySortVoice:
+		sortColumn = "voice_count"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be recent, members or voice"})
+		return
+	}
+
+	limit := directoryDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, directoryMaxLimit)
+	}
+
+	var cursor *directoryCursor
+	if value := c.Query("cursor"); value != "" {
+		decoded, err := decodeDirectoryCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		cursor = decoded
+	}
+
+	inner := db.DB.Model(&db.Room{}).
+		Select(`rooms.id, rooms.room_id, rooms.user_id, rooms.name, rooms.created_at,
+			COALESCE(rooms.description, '') AS description, COALESCE(rooms.tags, '') AS tags, COALESCE(rooms.password, '') AS password,
+			(SELECT COUNT(*) FROM room_members WHERE room_members.room_id = rooms.room_id) AS member_count,
+			(SELECT COUNT(*) FROM room_voice_participants WHERE room_voice_participants.room_id = rooms.room_id) AS voice_count`).
+		Where("rooms.type = ?", "public")
+	inner = excludeDirectRooms(inner)
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		inner = inner.Where(`LOWER(rooms.name) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(q))+"%")
+	}
+	if tag := strings.ToLower(strings.TrimSpace(c.Query("tag"))); tag != "" {
+		inner = inner.Where(`(',' || COALESCE(rooms.tags, '') || ',') LIKE ? ESCAPE '\'`, "%,"+escapeLike(tag)+",%")
+	}
+
+	query := db.DB.Table("(?) AS directory", inner)
+	if cursor != nil {
+		if sortColumn == "id" {
+			query = query.Where("id < ?", cursor.ID)
+		} else {
+			query = query.Where(sortColumn+" < ? OR ("+sortColumn+" = ? AND id < ?)", cursor.Value, cursor.Value, cursor.ID)
+		}
+	}
+	if sortColumn != "id" {
+		query = query.Order(sortColumn + " DESC")
+	}
+
+	var rows []roomDirectoryRow
+	if err := query.Order("id DESC").Limit(limit + 1).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room directory"})
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		next := directoryCursor{ID: last.ID}
+		switch sortBy {
+		case directorySortMembers:
+			next.Value = last.MemberCount
+		case directorySortVoice:
+			next.Value = last.VoiceCount
+		}
+		nextCursor = encodeDirectoryCursor(next)
+	}
+
+	rooms := make([]roomDirectoryEntry, 0, len(rows))
+	for _, row := range rows {
+		rooms = append(rooms, roomDirectoryEntry{
+			ID:          row.ID,
+			RoomID:      row.RoomID,
+			UserID:      row.UserID,
+			Name:        row.Name,
+			Description: row.Description,
+			Tags:        splitRoomTags(row.Tags),
+			HasPassword: row.Password != "",
+			MemberCount: row.MemberCount,
+			VoiceCount:  row.VoiceCount,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms, "next_cursor": nextCursor})
+}
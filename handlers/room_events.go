@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Room event names pushed to subscribed chat sockets.
+const (
+	roomEventMemberJoined = "member_joined"
+	roomEventMemberLeft   = "member_left"
+	roomEventMemberRole   = "member_role_changed"
+	roomEventVoiceJoined  = "voice_joined"
+	roomEventVoiceLeft    = "voice_left"
+	roomEventMediaChanged = "media_changed"
+	roomEventRoomUpdated  = "room_updated"
+	roomEventRoomDeleted  = "room_deleted"
+)
+
+// roomEvent is the frame pushed to clients when room state changes.
+type roomEvent struct {
+	Type   string      `json:"type"` // always "room_event"
+	Event  string      `json:"event"`
+	RoomID string      `json:"room_id"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// Подписки сокетов на события комнат
+// key - RoomID, value - подписанные подключения
+var roomSubscriptions = struct {
+	sync.RWMutex
+	rooms map[string]map[*chatClient]struct{}
+}{
+	rooms: make(map[string]map[*chatClient]struct{}),
+}
+
+// canViewRoomState applies the GetRoomState visibility rule:
+// members always, everyone else only for public rooms, banned users never.
+func canViewRoomState(room *db.Room, userID string) (bool, error) {
+	banned, err := isRoomBanned(room.RoomID, userID)
+	if err != nil || banned {
+		return false, err
+	}
+	if room.Type == "public" {
+		return true, nil
+	}
+
+	var member db.RoomMember
+	err = db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, userID).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// subscribeRoom authorizes the socket's user and registers it for the room's events.
+func subscribeRoom(client *chatClient, roomParam string) (string, error) {
+	room, err := resolveRoomByParam(roomParam)
+	if err != nil {
+		return "", errors.New("Room not found")
+	}
+
+	allowed, err := canViewRoomState(room, client.userID)
+	if err != nil {
+		return room.RoomID, errors.New("Failed to verify room membership")
+	}
+	if !allowed {
+		return room.RoomID, errors.New("Not a room member")
+	}
+
+	roomSubscriptions.Lock()
+	subscribers, ok := roomSubscriptions.rooms[room.RoomID]
+	if !ok {
+		subscribers = make(map[*chatClient]struct{})
+		roomSubscriptions.rooms[room.RoomID] = subscribers
+	}
+	subscribers[client] = struct{}{}
+	roomSubscriptions.Unlock()
+
+	return room.RoomID, nil
+}
+
+// unsubscribeRoom removes one socket from a room's subscribers.
+func unsubscribeRoom(client *chatClient, roomID string) string {
+	if room, err := resolveRoomByParam(roomID); err == nil {
+		roomID = room.RoomID
+	}
+
+	roomSubscriptions.Lock()
+	removeSubscriberLocked(roomID, client)
+	roomSubscriptions.Unlock()
+
+	return roomID
+}
+
+// unsubscribeAll removes a socket from every room, used when it disconnects.
+func unsubscribeAll(client *chatClient) {
+	roomSubscriptions.Lock()
+	defer roomSubscriptions.Unlock()
+
+	for roomID := range roomSubscriptions.rooms {
+		removeSubscriberLocked(roomID, client)
+	}
+}
+
+func removeSubscriberLocked(roomID string, client *chatClient) {
+	subscribers, ok := roomSubscriptions.rooms[roomID]
+	if !ok {
+		return
+	}
+	delete(subscribers, client)
+	if len(subscribers) == 0 {
+		delete(roomSubscriptions.rooms, roomID)
+	}
+}
+
+// roomSubscribers snapshots the sockets subscribed to a room.
+func roomSubscribers(roomID string) []*chatClient {
+	roomSubscriptions.RLock()
+	defer roomSubscriptions.RUnlock()
+
+	subscribers := make([]*chatClient, 0, len(roomSubscriptions.rooms[roomID]))
+	for client := range roomSubscriptions.rooms[roomID] {
+		subscribers = append(subscribers, client)
+	}
+	return subscribers
+}
+
+// publishRoomEvent queues an event for every socket subscribed to the room.
+// It never blocks: a subscriber that cannot keep up is disconnected.
+func publishRoomEvent(roomID, event string, data interface{}) {
+	subscribers := roomSubscribers(roomID)
+	frame := roomEvent{
+		Type:   "room_event",
+		Event:  event,
+		RoomID: roomID,
+		Data:   data,
+	}
+	for _, client := range subscribers {
+		if !client.enqueue(frame) {
+			utils.Logger.Warn("failed to push room event", "room_id", roomID, "event", event, "user_id", client.userID)
+		}
+	}
+}
+
+// pruneRoomSubscribers drops subscribers that may no longer see the room,
+// e.g. after it turned private or a member was removed.
+func pruneRoomSubscribers(room *db.Room) {
+	for _, client := range roomSubscribers(room.RoomID) {
+		allowed, err := canViewRoomState(room, client.userID)
+		if err != nil || allowed {
+			continue
+		}
+		roomSubscriptions.Lock()
+		removeSubscriberLocked(room.RoomID, client)
+		roomSubscriptions.Unlock()
+	}
+}
+
+// dropUserRoomSubscriptions unsubscribes every socket of a user from a room,
+// used when they are banned and the room's visibility rule would still let them in.
+func dropUserRoomSubscriptions(roomID, userID string) {
+	roomSubscriptions.Lock()
+	defer roomSubscriptions.Unlock()
+
+	for client := range roomSubscriptions.rooms[roomID] {
+		if client.userID == userID {
+			removeSubscriberLocked(roomID, client)
+		}
+	}
+}
+
+// dropRoomSubscriptions forgets every subscriber of a deleted room.
+func dropRoomSubscriptions(roomID string) {
+	roomSubscriptions.Lock()
+	delete(roomSubscriptions.rooms, roomID)
+	roomSubscriptions.Unlock()
+}
+
+// voiceEventData is the payload of voice_joined and media_changed events.
+func voiceEventData(participant *db.RoomVoiceParticipant) gin.H {
+	return gin.H{
+		"user_id":           participant.UserID,
+		"is_mic_enabled":    participant.IsMicEnabled,
+		"is_camera_enabled": participant.IsCameraEnabled,
+		"is_screen_sharing": participant.IsScreenSharing,
+	}
+}
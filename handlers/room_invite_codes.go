@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invite code limits.
+const (
+	inviteCodeBytes     = 9 // 12 URL-safe characters
+	maxInviteCodeExpiry = 30 * 24 * time.Hour
+)
+
+var (
+	errInviteCodeUnavailable = errors.New("invite code is expired, revoked or used up")
+	errInviteCodeBanned      = errors.New("banned from room")
+	errInviteCodeMember      = errors.New("already a room member")
+)
+
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// inviteCodeActive reports whether a code can still be redeemed.
+func inviteCodeActive(code *db.RoomInviteCode, now time.Time) bool {
+	if code.RevokedAt != nil {
+		return false
+	}
+	if code.ExpiresAt != nil && !now.Before(*code.ExpiresAt) {
+		return false
+	}
+	return code.MaxUses == 0 || code.Uses < code.MaxUses
+}
+
+// CreateRoomInviteCode generates a shareable invite code. Creator or admins only;
+// only the creator can hand out admin codes.
+func CreateRoomInviteCode(c *gin.Context) {
+	var req struct {
+		ExpiresIn int    `json:"expires_in" binding:"min=0"` // seconds, 0 = never
+		MaxUses   int    `json:"max_uses" binding:"min=0"`   // 0 = unlimited
+		Role      string `json:"role" binding:"omitempty,oneof=admin member viewer"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = roomRoleMember
+	}
+	if time.Duration(req.ExpiresIn)*time.Second > maxInviteCodeExpiry {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in is too long"})
+		return
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+	if !outranks(actor.Role, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a role equal to or above your own"})
+		return
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+
+	inviteCode := db.RoomInviteCode{
+		RoomID:    room.RoomID,
+		Code:      code,
+		CreatedBy: actor.UserID,
+		Role:      req.Role,
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		inviteCode.ExpiresAt = &expiresAt
+	}
+	if err := db.DB.Create(&inviteCode).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invite_code": inviteCode})
+}
+
+// GetRoomInviteCodes lists the room's invite codes with their state. Creator or admins only.
+func GetRoomInviteCodes(c *gin.Context) {
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+
+	var codes []db.RoomInviteCode
+	if err := db.DB.Where("room_id = ?", room.RoomID).Order("created_at DESC").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite codes"})
+		return
+	}
+
+	now := time.Now()
+	response := make([]gin.H, 0, len(codes))
+	for i := range codes {
+		response = append(response, gin.H{
+			"invite_code": codes[i],
+			"active":      inviteCodeActive(&codes[i], now),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invite_codes": response})
+}
+
+// RevokeRoomInviteCode stops a code from being redeemed. Creator or admins only.
+func RevokeRoomInviteCode(c *gin.Context) {
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+
+	result := db.DB.Model(&db.RoomInviteCode{}).
+		Where("room_id = ? AND code = ? AND revoked_at IS NULL", room.RoomID, c.Param("code")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite code"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite code not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite code revoked"})
+}
+
+// JoinRoomByCode redeems an invite code for the authenticated user.
+// Password-protected rooms still require the password, and banned users are refused.
+func JoinRoomByCode(c *gin.Context) {
+	var req struct {
+		Code     string `json:"code" binding:"required"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	var inviteCode db.RoomInviteCode
+	if err := db.DB.Where("code = ?", req.Code).First(&inviteCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite code not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite code"})
+		}
+		return
+	}
+
+	room, err := resolveRoomByParam(inviteCode.RoomID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	utils.SetLogRoom(c, room.RoomID)
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return
+	}
+	if member != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Already in room", "room_id": room.RoomID, "role": member.Role})
+		return
+	}
+
+	if room.Password != "" && subtle.ConstantTimeCompare([]byte(room.Password), []byte(req.Password)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid room password"})
+		return
+	}
+
+	newMember := db.RoomMember{
+		RoomID: room.RoomID,
+		UserID: currentUser.UserID,
+		Role:   inviteCode.Role,
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var bans int64
+		if err := tx.Model(&db.RoomBan{}).Where("room_id = ? AND user_id = ?", room.RoomID, currentUser.UserID).Count(&bans).Error; err != nil {
+			return err
+		}
+		if bans > 0 {
+			return errInviteCodeBanned
+		}
+
+		// Счётчик использований увеличиваем атомарно, чтобы не превысить max_uses
+		result := tx.Model(&db.RoomInviteCode{}).
+			Where("id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses = 0 OR uses < max_uses)",
+				inviteCode.ID, time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInviteCodeUnavailable
+		}
+
+		// Параллельный вход уже добавил участника — откатываем списание использования
+		result = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+			DoNothing: true,
+		}).Create(&newMember)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInviteCodeMember
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInviteCodeMember):
+		var existing db.RoomMember
+		if err := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, currentUser.UserID).First(&existing).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Already in room", "room_id": room.RoomID, "role": existing.Role})
+		return
+	case errors.Is(err, errInviteCodeBanned):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this room"})
+		return
+	case errors.Is(err, errInviteCodeUnavailable):
+		c.JSON(http.StatusGone, gin.H{"error": "Invite code is expired, revoked or used up"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room"})
+		return
+	}
+
+	publishRoomEvent(room.RoomID, roomEventMemberJoined, gin.H{"user_id": newMember.UserID, "role": newMember.Role})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Joined room", "room_id": room.RoomID, "role": newMember.Role})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"GoCall_api/db"
+
+	"gorm.io/gorm"
+)
+
+func TestJoinRoomByCodeConcurrentJoin(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	seedMember(t, testUserID, roomRoleCreator, false)
+	joinerID := seedUser(t, testTargetID, "bob")
+
+	inviteCode := db.RoomInviteCode{RoomID: testRoomID, Code: "race", CreatedBy: testUserID, Role: roomRoleMember, MaxUses: 1}
+	if err := db.DB.Create(&inviteCode).Error; err != nil {
+		t.Fatalf("create invite code: %v", err)
+	}
+
+	// Имитируем параллельный вход: участник появляется сразу после проверки членства.
+	// Колбэк живёт до конца теста: setupTestDB каждый раз открывает новую БД
+	inserted := false
+	callback := db.DB.Callback().Query()
+	if err := callback.After("gorm:query").Register("test:concurrent_join", func(tx *gorm.DB) {
+		if tx.Statement.Table == "room_members" && !inserted {
+			inserted = true
+			db.DB.Create(&db.RoomMember{RoomID: testRoomID, UserID: testTargetID, Role: roomRoleAdmin})
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	w := callHandler(JoinRoomByCode, joinerID, `{"code":"race"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Already in room") {
+		t.Fatalf("status = %d, body = %s, want Already in room", w.Code, w.Body)
+	}
+
+	if err := db.DB.First(&inviteCode, inviteCode.ID).Error; err != nil {
+		t.Fatalf("fetch invite code: %v", err)
+	}
+	if inviteCode.Uses != 0 {
+		t.Fatalf("uses = %d, want 0", inviteCode.Uses)
+	}
+	if !strings.Contains(w.Body.String(), `"role":"`+roomRoleAdmin+`"`) {
+		t.Fatalf("body = %s, want the existing member's role", w.Body)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxRoomInviteExpiry caps the optional expires_in of a room invite.
+const maxRoomInviteExpiry = 30 * 24 * time.Hour
+
+// roomInviteEntry is a pending room invite as listed to its invitee (Room set)
+// or to the room's moderators (Invitee set).
+type roomInviteEntry struct {
+	ID        uint            `json:"id"`
+	Room      *roomInviteRoom `json:"room,omitempty"`
+	Inviter   userProfile     `json:"inviter"`
+	Invitee   *userProfile    `json:"invitee,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+}
+
+// roomInviteRoom is the part of the room shown with an invite.
+type roomInviteRoom struct {
+	RoomID string `json:"room_id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+type roomInviteRow struct {
+	ID              uint
+	CreatedAt       time.Time
+	ExpiresAt       *time.Time
+	RoomID          string
+	RoomName        string
+	RoomType        string
+	InviterUserID   string
+	InviterUsername string
+	InviterName     string
+	InviteeUserID   string
+	InviteeUsername string
+	InviteeName     string
+}
+
+// roomInviteExpired reports whether a pending invite has passed its expiry.
+func roomInviteExpired(invite *db.RoomInvite, now time.Time) bool {
+	return invite.ExpiresAt != nil && !now.Before(*invite.ExpiresAt)
+}
+
+// queryPendingRoomInvites lists open invites, newest first: pending, not expired,
+// for a room that still exists and a user who has not joined it some other way.
+func queryPendingRoomInvites(where string, args ...interface{}) ([]roomInviteRow, error) {
+	var rows []roomInviteRow
+	err := db.DB.Table("room_invites ri").
+		Select(`ri.id, ri.created_at, ri.expires_at, r.room_id, r.name AS room_name, r.type AS room_type,
+			inviter.user_id AS inviter_user_id, inviter.username AS inviter_username, COALESCE(inviter.name, '') AS inviter_name,
+			invitee.user_id AS invitee_user_id, invitee.username AS invitee_username, COALESCE(invitee.name, '') AS invitee_name`).
+		Joins("JOIN rooms r ON r.room_id = ri.room_id").
+		Joins("JOIN users inviter ON inviter.user_id = ri.inviter_user_id").
+		Joins("JOIN users invitee ON invitee.user_id = ri.invited_user_id").
+		Where("ri.status = 'pending' AND (ri.expires_at IS NULL OR ri.expires_at > ?)", time.Now()).
+		Where("NOT EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = ri.room_id AND rm.user_id = ri.invited_user_id)").
+		Where(where, args...).
+		Order("ri.created_at DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetRoomInvites lists the caller's open room invites with the room and the inviter.
+func GetRoomInvites(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	rows, err := queryPendingRoomInvites("ri.invited_user_id = ?", currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	invites := make([]roomInviteEntry, len(rows))
+	for i, row := range rows {
+		invites[i] = roomInviteEntry{
+			ID:        row.ID,
+			Room:      &roomInviteRoom{RoomID: row.RoomID, Name: row.RoomName, Type: row.RoomType},
+			Inviter:   userProfile{UserID: row.InviterUserID, Username: row.InviterUsername, Name: row.InviterName},
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.ExpiresAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// GetRoomSentInvites lists the room's open invites with inviter and invitee. Creator or admins only.
+func GetRoomSentInvites(c *gin.Context) {
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+
+	rows, err := queryPendingRoomInvites("ri.room_id = ?", room.RoomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	invites := make([]roomInviteEntry, len(rows))
+	for i, row := range rows {
+		invites[i] = roomInviteEntry{
+			ID:        row.ID,
+			Inviter:   userProfile{UserID: row.InviterUserID, Username: row.InviterUsername, Name: row.InviterName},
+			Invitee:   &userProfile{UserID: row.InviteeUserID, Username: row.InviteeUsername, Name: row.InviteeName},
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.ExpiresAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room_id": room.RoomID, "invites": invites})
+}
+
+// CancelRoomInvite withdraws a pending invite of the room. Creator or admins only.
+func CancelRoomInvite(c *gin.Context) {
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+	inviteID, err := strconv.ParseUint(c.Param("inviteId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite id"})
+		return
+	}
+
+	var invite db.RoomInvite
+	if err := db.DB.Where("id = ? AND room_id = ? AND status = 'pending'", inviteID, room.RoomID).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite"})
+		}
+		return
+	}
+	if err := db.DB.Delete(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel invite"})
+		return
+	}
+
+	retractNotification(invite.InvitedUserID, notificationRoomInvite, invite.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite cancelled", "invite_id": invite.ID})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"GoCall_api/db"
+
+	"gorm.io/gorm"
+)
+
+// seedRoomInvite stores a pending invite to the test room from testUserID to testTargetID.
+func seedRoomInvite(t *testing.T) db.RoomInvite {
+	t.Helper()
+	invite := db.RoomInvite{RoomID: testRoomID, InviterUserID: testUserID, InvitedUserID: testTargetID, Status: "pending"}
+	if err := db.DB.Create(&invite).Error; err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	return invite
+}
+
+func inviteBody(invite db.RoomInvite) string {
+	return `{"invite_id":` + strconv.FormatUint(uint64(invite.ID), 10) + `}`
+}
+
+func TestAcceptRoomInvite(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	inviteeID := seedUser(t, testTargetID, "bob")
+	invite := seedRoomInvite(t)
+
+	if w := callHandler(AcceptRoomInvite, inviteeID, inviteBody(invite)); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	db.DB.First(&invite, invite.ID)
+	var members int64
+	db.DB.Model(&db.RoomMember{}).Where("room_id = ? AND user_id = ?", testRoomID, testTargetID).Count(&members)
+	if invite.Status != "accepted" || members != 1 {
+		t.Fatalf("status = %q, members = %d, want accepted and 1", invite.Status, members)
+	}
+}
+
+func TestAcceptRoomInviteMemberInsertFails(t *testing.T) {
+	setupTestDB(t)
+	seedRoom(t)
+	inviteeID := seedUser(t, testTargetID, "bob")
+	invite := seedRoomInvite(t)
+
+	// Колбэк живёт до конца теста: setupTestDB каждый раз открывает новую БД
+	if err := db.DB.Callback().Create().Before("gorm:create").Register("test:fail_member", func(tx *gorm.DB) {
+		if tx.Statement.Table == "room_members" {
+			tx.AddError(errors.New("insert failed"))
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if w := callHandler(AcceptRoomInvite, inviteeID, inviteBody(invite)); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusInternalServerError, w.Body)
+	}
+
+	db.DB.First(&invite, invite.ID)
+	if invite.Status != "pending" {
+		t.Fatalf("invite status = %q, want pending after the failed join", invite.Status)
+	}
+}
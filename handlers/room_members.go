@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// isRoomBanned reports whether the user is on the room's ban list.
+func isRoomBanned(roomID, userID string) (bool, error) {
+	var count int64
+	if err := db.DB.Model(&db.RoomBan{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// removeRoomMember deletes a membership together with the user's voice presence,
+// disconnects them from LiveKit when possible and notifies subscribers.
+func removeRoomMember(c *gin.Context, room *db.Room, userID, reason string) error {
+	var voiceRemoved int64
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ? AND user_id = ?", room.RoomID, userID).Delete(&db.RoomMember{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("room_id = ? AND user_id = ?", room.RoomID, userID).Delete(&db.RoomVoiceParticipant{})
+		voiceRemoved = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		return err
+	}
+
+	if voiceRemoved > 0 {
+		if RoomService != nil {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), roomServiceTimeout)
+			if err := RoomService.RemoveParticipant(ctx, room.RoomID, userID); err != nil {
+				utils.Log(c).Warn("failed to remove livekit participant", "target", userID, "error", err)
+			}
+			cancel()
+		}
+		publishRoomEvent(room.RoomID, roomEventVoiceLeft, gin.H{"user_id": userID, "reason": reason})
+	}
+
+	publishRoomEvent(room.RoomID, roomEventMemberLeft, gin.H{"user_id": userID, "reason": reason})
+	pruneRoomSubscribers(room)
+	return nil
+}
+
+// loadRoomTarget fetches the target membership and checks the caller outranks it.
+func loadRoomTarget(c *gin.Context, room *db.Room, actor *db.RoomMember, targetUserID string) (*db.RoomMember, bool) {
+	var target db.RoomMember
+	if err := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, targetUserID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target user not in room"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room member"})
+		}
+		return nil, false
+	}
+	if !outranks(actor.Role, target.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot moderate a member with an equal or higher role"})
+		return nil, false
+	}
+	return &target, true
+}
+
+// LeaveRoom removes the authenticated user from the room. The creator cannot leave their own room.
+func LeaveRoom(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not a room member"})
+		return
+	}
+	if rejectDirectRoom(c, room) {
+		return
+	}
+	if member.Role == roomRoleCreator {
+		c.JSON(http.StatusConflict, gin.H{"error": "Creator cannot leave the room; delete it instead"})
+		return
+	}
+
+	if err := removeRoomMember(c, room, currentUser.UserID, "left"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left room", "room_id": room.RoomID})
+}
+
+// KickRoomMember removes a lower-ranked member from the room. They may rejoin public rooms.
+func KickRoomMember(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+	if _, ok := loadRoomTarget(c, room, actor, req.UserID); !ok {
+		return
+	}
+
+	if err := removeRoomMember(c, room, req.UserID, "kicked"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to kick member"})
+		return
+	}
+
+	utils.Log(c).Info("kicked room member", "target", req.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Member kicked", "room_id": room.RoomID, "user_id": req.UserID})
+}
+
+// BanRoomMember bans a user from the room, removing their membership and pending invites.
+// Users who are not members can be banned pre-emptively.
+func BanRoomMember(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		Reason string `json:"reason" binding:"max=200"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+	if req.UserID == actor.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot ban yourself"})
+		return
+	}
+
+	var user db.User
+	if err := db.DB.Where("user_id = ?", req.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	member, err := ensureRoomMember(room, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room member"})
+		return
+	}
+	if member != nil && !outranks(actor.Role, member.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot moderate a member with an equal or higher role"})
+		return
+	}
+
+	if banned, err := isRoomBanned(room.RoomID, user.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check room bans"})
+		return
+	} else if banned {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already banned"})
+		return
+	}
+
+	ban := db.RoomBan{
+		RoomID:   room.RoomID,
+		UserID:   user.UserID,
+		BannedBy: actor.UserID,
+		Reason:   req.Reason,
+	}
+	if err := db.DB.Create(&ban).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
+		return
+	}
+	db.DB.Where("room_id = ? AND invited_user_id = ? AND status = 'pending'", room.RoomID, user.UserID).
+		Delete(&db.RoomInvite{})
+
+	if member != nil {
+		if err := removeRoomMember(c, room, user.UserID, "banned"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove banned member"})
+			return
+		}
+	}
+	dropUserRoomSubscriptions(room.RoomID, user.UserID)
+
+	utils.Log(c).Info("banned room member", "target", user.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "User banned", "ban": ban})
+}
+
+// UnbanRoomMember lifts a ban. The user has to join or be invited again.
+func UnbanRoomMember(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+
+	result := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, req.UserID).Delete(&db.RoomBan{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not banned"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unbanned", "room_id": room.RoomID, "user_id": req.UserID})
+}
+
+// GetRoomBans lists the room's ban list for its creator and admins.
+func GetRoomBans(c *gin.Context) {
+	room, _, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+
+	var bans []db.RoomBan
+	if err := db.DB.Where("room_id = ?", room.RoomID).Order("created_at DESC").Find(&bans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room bans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bans": bans})
+}
+
+// DemoteRoomMember lowers a member's role to member (default) or viewer.
+func DemoteRoomMember(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		Role   string `json:"role" binding:"omitempty,oneof=member viewer"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = roomRoleMember
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok {
+		return
+	}
+	target, ok := loadRoomTarget(c, room, actor, req.UserID)
+	if !ok {
+		return
+	}
+	if !roleAtLeast(target.Role, req.Role) || target.Role == req.Role {
+		c.JSON(http.StatusConflict, gin.H{"error": "Target role is not above the requested role"})
+		return
+	}
+
+	target.Role = req.Role
+	if err := db.DB.Save(target).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to demote member"})
+		return
+	}
+
+	publishRoomEvent(room.RoomID, roomEventMemberRole, gin.H{"user_id": target.UserID, "role": target.Role})
+	notify(db.Notification{UserID: target.UserID, Type: notificationRoomRole, ActorID: actor.UserID, RoomID: room.RoomID, Role: target.Role})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member demoted", "room_id": room.RoomID, "user_id": target.UserID, "role": target.Role})
+}
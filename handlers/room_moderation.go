@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"GoCall_api/db"
+	"GoCall_api/roomservice"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livekit/protocol/livekit"
+	"gorm.io/gorm"
+)
+
+// RoomService enforces moderation in LiveKit. main sets it when LiveKit is configured;
+// tests can swap in roomservice.NewFake().
+var RoomService roomservice.Client
+
+const roomServiceTimeout = 10 * time.Second
+
+// outranks reports whether a moderator with role actor may act on a member with role target.
+func outranks(actor, target string) bool {
+	return isRoomModerator(actor) && roomRoleRank[actor] > roomRoleRank[target]
+}
+
+// requireRoomModerator resolves the room from :id and checks the caller is its creator or an admin.
+// Direct rooms have no moderation.
+func requireRoomModerator(c *gin.Context) (*db.Room, *db.RoomMember, bool) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return nil, nil, false
+	}
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return nil, nil, false
+	}
+	if member == nil || !isRoomModerator(member.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permissions"})
+		return nil, nil, false
+	}
+	if rejectDirectRoom(c, room) {
+		return nil, nil, false
+	}
+
+	return room, member, true
+}
+
+// requireRoomService answers 503 when LiveKit moderation is unavailable.
+func requireRoomService(c *gin.Context) bool {
+	if RoomService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LiveKit is not configured"})
+		return false
+	}
+	return true
+}
+
+// loadVoiceTarget fetches the target's membership and voice presence and checks the actor outranks them.
+func loadVoiceTarget(c *gin.Context, room *db.Room, actor *db.RoomMember, targetUserID string) (*db.RoomVoiceParticipant, bool) {
+	var target db.RoomMember
+	if err := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, targetUserID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a room member"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room member"})
+		}
+		return nil, false
+	}
+	if !outranks(actor.Role, target.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot moderate a member with an equal or higher role"})
+		return nil, false
+	}
+
+	var voiceParticipant db.RoomVoiceParticipant
+	if err := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, targetUserID).First(&voiceParticipant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not in room voice"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room voice participant"})
+		}
+		return nil, false
+	}
+
+	return &voiceParticipant, true
+}
+
+// MuteRoomVoiceParticipant force-mutes a participant's microphone, camera and/or screen share.
+// Without any flag set, every source is muted.
+func MuteRoomVoiceParticipant(c *gin.Context) {
+	var req struct {
+		UserID      string `json:"user_id" binding:"required"`
+		Mic         bool   `json:"mic"`
+		Camera      bool   `json:"camera"`
+		ScreenShare bool   `json:"screen_share"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !req.Mic && !req.Camera && !req.ScreenShare {
+		req.Mic, req.Camera, req.ScreenShare = true, true, true
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok || !requireRoomService(c) {
+		return
+	}
+	voiceParticipant, ok := loadVoiceTarget(c, room, actor, req.UserID)
+	if !ok {
+		return
+	}
+
+	var sources []livekit.TrackSource
+	if req.Mic {
+		sources = append(sources, livekit.TrackSource_MICROPHONE)
+		voiceParticipant.IsMicEnabled = false
+	}
+	if req.Camera {
+		sources = append(sources, livekit.TrackSource_CAMERA)
+		voiceParticipant.IsCameraEnabled = false
+	}
+	if req.ScreenShare {
+		sources = append(sources, livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO)
+		voiceParticipant.IsScreenSharing = false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), roomServiceTimeout)
+	defer cancel()
+	if err := RoomService.MuteSources(ctx, room.RoomID, req.UserID, sources...); err != nil {
+		utils.Log(c).Error("failed to mute livekit participant", "target", req.UserID, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to mute participant in LiveKit"})
+		return
+	}
+
+	if err := db.DB.Save(voiceParticipant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room voice media state"})
+		return
+	}
+
+	data := voiceEventData(voiceParticipant)
+	data["moderated_by"] = actor.UserID
+	publishRoomEvent(room.RoomID, roomEventMediaChanged, data)
+
+	utils.Log(c).Info("muted voice participant", "target", req.UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Participant muted",
+		"room_id":           room.RoomID,
+		"user_id":           req.UserID,
+		"is_mic_enabled":    voiceParticipant.IsMicEnabled,
+		"is_camera_enabled": voiceParticipant.IsCameraEnabled,
+		"is_screen_sharing": voiceParticipant.IsScreenSharing,
+	})
+}
+
+// KickRoomVoiceParticipant disconnects a participant from room voice. They stay a room member.
+func KickRoomVoiceParticipant(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	room, actor, ok := requireRoomModerator(c)
+	if !ok || !requireRoomService(c) {
+		return
+	}
+	if _, ok := loadVoiceTarget(c, room, actor, req.UserID); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), roomServiceTimeout)
+	defer cancel()
+	if err := RoomService.RemoveParticipant(ctx, room.RoomID, req.UserID); err != nil {
+		utils.Log(c).Error("failed to remove livekit participant", "target", req.UserID, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to remove participant from LiveKit"})
+		return
+	}
+
+	if err := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, req.UserID).Delete(&db.RoomVoiceParticipant{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove room voice participant"})
+		return
+	}
+
+	publishRoomEvent(room.RoomID, roomEventVoiceLeft, gin.H{"user_id": req.UserID, "reason": "kicked", "moderated_by": actor.UserID})
+
+	utils.Log(c).Info("kicked voice participant", "target", req.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Participant removed from voice", "room_id": room.RoomID, "user_id": req.UserID})
+}
+
+// EndRoomVoice closes the room's LiveKit session and clears all voice presence.
+func EndRoomVoice(c *gin.Context) {
+	room, actor, ok := requireRoomModerator(c)
+	if !ok || !requireRoomService(c) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), roomServiceTimeout)
+	defer cancel()
+	if err := RoomService.DeleteRoom(ctx, room.RoomID); err != nil {
+		utils.Log(c).Error("failed to close livekit room", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to end the LiveKit session"})
+		return
+	}
+
+	var participants []db.RoomVoiceParticipant
+	if err := db.DB.Where("room_id = ?", room.RoomID).Find(&participants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room voice participants"})
+		return
+	}
+	if err := db.DB.Where("room_id = ?", room.RoomID).Delete(&db.RoomVoiceParticipant{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear room voice participants"})
+		return
+	}
+
+	for _, participant := range participants {
+		publishRoomEvent(room.RoomID, roomEventVoiceLeft, gin.H{"user_id": participant.UserID, "reason": "ended", "moderated_by": actor.UserID})
+	}
+
+	utils.Log(c).Info("ended room voice", "participants", len(participants))
+	c.JSON(http.StatusOK, gin.H{"message": "Room voice ended", "room_id": room.RoomID, "removed": len(participants)})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"GoCall_api/db"
+	"GoCall_api/roomservice"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// setupModeration seeds the test room with testUserID as admin and testTargetID as a
+// member in voice, and installs a fake RoomService. It returns the admin's users.id.
+func setupModeration(t *testing.T) (uint, *roomservice.Fake) {
+	t.Helper()
+	setupTestDB(t)
+	seedRoom(t)
+
+	adminID := seedUser(t, testUserID, "admin")
+	seedMember(t, testUserID, roomRoleAdmin, false)
+	seedMember(t, testTargetID, roomRoleMember, true)
+
+	fake := roomservice.NewFake()
+	previous := RoomService
+	RoomService = fake
+	t.Cleanup(func() { RoomService = previous })
+	return adminID, fake
+}
+
+func TestMuteRoomVoiceParticipant(t *testing.T) {
+	adminID, fake := setupModeration(t)
+
+	w := callHandler(MuteRoomVoiceParticipant, adminID, `{"user_id":"`+testTargetID+`","mic":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	want := []roomservice.Call{{Method: "MuteSources", Room: testRoomID, Identity: testTargetID, Sources: []livekit.TrackSource{livekit.TrackSource_MICROPHONE}}}
+	if got := fake.Recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %+v, want %+v", got, want)
+	}
+	participant, found := voiceParticipant(t, testTargetID)
+	if !found || participant.IsMicEnabled || !participant.IsCameraEnabled {
+		t.Fatalf("participant = %+v (found %v), want mic off and camera on", participant, found)
+	}
+}
+
+func TestMuteRoomVoiceParticipantAllSources(t *testing.T) {
+	adminID, fake := setupModeration(t)
+
+	if w := callHandler(MuteRoomVoiceParticipant, adminID, `{"user_id":"`+testTargetID+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	calls := fake.Recorded()
+	if len(calls) != 1 || len(calls[0].Sources) != 4 {
+		t.Fatalf("calls = %+v, want one MuteSources call for every source", calls)
+	}
+	participant, _ := voiceParticipant(t, testTargetID)
+	if participant.IsMicEnabled || participant.IsCameraEnabled || participant.IsScreenSharing {
+		t.Fatalf("participant = %+v, want every source off", participant)
+	}
+}
+
+func TestMuteRoomVoiceParticipantLiveKitFailure(t *testing.T) {
+	adminID, fake := setupModeration(t)
+	fake.Err = errors.New("unavailable")
+
+	if w := callHandler(MuteRoomVoiceParticipant, adminID, `{"user_id":"`+testTargetID+`","mic":true}`); w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+	if participant, _ := voiceParticipant(t, testTargetID); !participant.IsMicEnabled {
+		t.Fatal("mic flag cleared although LiveKit failed")
+	}
+}
+
+func TestModerationRequiresHigherRole(t *testing.T) {
+	adminID, fake := setupModeration(t)
+	if err := db.DB.Model(&db.RoomMember{}).Where("user_id = ?", testTargetID).Update("role", roomRoleAdmin).Error; err != nil {
+		t.Fatalf("promote target: %v", err)
+	}
+
+	if w := callHandler(KickRoomVoiceParticipant, adminID, `{"user_id":"`+testTargetID+`"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if calls := fake.Recorded(); len(calls) != 0 {
+		t.Fatalf("calls = %+v, want none", calls)
+	}
+}
+
+func TestKickRoomVoiceParticipant(t *testing.T) {
+	adminID, fake := setupModeration(t)
+
+	if w := callHandler(KickRoomVoiceParticipant, adminID, `{"user_id":"`+testTargetID+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	want := []roomservice.Call{{Method: "RemoveParticipant", Room: testRoomID, Identity: testTargetID}}
+	if got := fake.Recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %+v, want %+v", got, want)
+	}
+	if _, found := voiceParticipant(t, testTargetID); found {
+		t.Fatal("kicked participant still in voice")
+	}
+	var members int64
+	db.DB.Model(&db.RoomMember{}).Where("room_id = ? AND user_id = ?", testRoomID, testTargetID).Count(&members)
+	if members != 1 {
+		t.Fatal("kicked participant lost room membership")
+	}
+}
+
+func TestEndRoomVoice(t *testing.T) {
+	adminID, fake := setupModeration(t)
+	if err := db.DB.Create(&db.RoomVoiceParticipant{RoomID: testRoomID, UserID: testUserID}).Error; err != nil {
+		t.Fatalf("create voice participant: %v", err)
+	}
+
+	if w := callHandler(EndRoomVoice, adminID, ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	want := []roomservice.Call{{Method: "DeleteRoom", Room: testRoomID}}
+	if got := fake.Recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %+v, want %+v", got, want)
+	}
+	var remaining int64
+	db.DB.Model(&db.RoomVoiceParticipant{}).Where("room_id = ?", testRoomID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("%d voice participants left, want 0", remaining)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roomOwnershipChange records a completed transfer so events can be published after commit.
+type roomOwnershipChange struct {
+	RoomID       string
+	OldOwnerID   string
+	NewOwnerID   string
+	OldOwnerRole string // role the previous owner keeps, empty if they left the room
+}
+
+// transferRoomOwnership moves Room.UserID and the creator role to newOwnerID inside tx.
+// The previous creator is demoted to admin unless keepOldOwner is false.
+func transferRoomOwnership(tx *gorm.DB, room *db.Room, newOwnerID string, keepOldOwner bool) (roomOwnershipChange, error) {
+	change := roomOwnershipChange{RoomID: room.RoomID, OldOwnerID: room.UserID, NewOwnerID: newOwnerID}
+
+	if err := tx.Model(&db.Room{}).Where("id = ?", room.ID).Update("user_id", newOwnerID).Error; err != nil {
+		return change, err
+	}
+	if err := tx.Model(&db.RoomMember{}).
+		Where("room_id = ? AND user_id = ?", room.RoomID, newOwnerID).
+		Update("role", roomRoleCreator).Error; err != nil {
+		return change, err
+	}
+	if keepOldOwner {
+		if err := tx.Model(&db.RoomMember{}).
+			Where("room_id = ? AND user_id = ?", room.RoomID, room.UserID).
+			Update("role", roomRoleAdmin).Error; err != nil {
+			return change, err
+		}
+		change.OldOwnerRole = roomRoleAdmin
+	}
+
+	room.UserID = newOwnerID
+	return change, nil
+}
+
+// roomSuccessor picks the member who inherits a room: the oldest admin,
+// otherwise the oldest member. Viewers never inherit. Returns "" if nobody qualifies.
+func roomSuccessor(tx *gorm.DB, roomID, ownerID string) (string, error) {
+	for _, role := range []string{roomRoleAdmin, roomRoleMember} {
+		var member db.RoomMember
+		err := tx.Where("room_id = ? AND user_id <> ? AND role = ?", roomID, ownerID, role).
+			Order("joined_at ASC, id ASC").
+			First(&member).Error
+		if err == nil {
+			return member.UserID, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// publishOwnershipChange notifies room subscribers about a completed transfer.
+func publishOwnershipChange(change roomOwnershipChange) {
+	publishRoomEvent(change.RoomID, roomEventMemberRole, gin.H{"user_id": change.NewOwnerID, "role": roomRoleCreator})
+	if change.OldOwnerRole != "" {
+		publishRoomEvent(change.RoomID, roomEventMemberRole, gin.H{"user_id": change.OldOwnerID, "role": change.OldOwnerRole})
+	}
+	publishRoomEvent(change.RoomID, roomEventRoomUpdated, gin.H{"user_id": change.NewOwnerID})
+	notify(db.Notification{UserID: change.NewOwnerID, Type: notificationRoomRole, ActorID: change.OldOwnerID, RoomID: change.RoomID, Role: roomRoleCreator})
+}
+
+// TransferRoomOwnership hands the room over to another member. Creator only;
+// the previous creator stays in the room as an admin.
+func TransferRoomOwnership(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return
+	}
+	if member == nil || member.Role != roomRoleCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only creator can transfer ownership"})
+		return
+	}
+	if req.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already own this room"})
+		return
+	}
+	if rejectDirectRoom(c, room) {
+		return
+	}
+
+	var change roomOwnershipChange
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var target db.RoomMember
+		if err := tx.Where("room_id = ? AND user_id = ?", room.RoomID, req.UserID).First(&target).Error; err != nil {
+			return err
+		}
+		change, err = transferRoomOwnership(tx, room, target.UserID, true)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target user not in room"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer ownership"})
+		}
+		return
+	}
+
+	publishOwnershipChange(change)
+
+	utils.Log(c).Info("transferred room ownership", "target", req.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transferred", "room_id": room.RoomID, "user_id": req.UserID})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+)
+
+// Room member roles, from most to least privileged.
+const (
+	roomRoleCreator = "creator"
+	roomRoleAdmin   = "admin"
+	roomRoleMember  = "member"
+	roomRoleViewer  = "viewer"
+)
+
+var roomRoleRank = map[string]int{
+	roomRoleCreator: 3,
+	roomRoleAdmin:   2,
+	roomRoleMember:  1,
+	roomRoleViewer:  0,
+}
+
+// roleAtLeast reports whether role is as privileged as min. Unknown roles rank as viewers.
+func roleAtLeast(role, min string) bool {
+	return roomRoleRank[role] >= roomRoleRank[min]
+}
+
+// isRoomModerator reports whether the role may moderate the room (creator or admin).
+func isRoomModerator(role string) bool {
+	return roleAtLeast(role, roomRoleAdmin)
+}
+
+// roomVoicePermissions is what a member may do in the room's LiveKit session.
+type roomVoicePermissions struct {
+	Role           string   `json:"role"`
+	CanPublish     bool     `json:"can_publish"`
+	CanSubscribe   bool     `json:"can_subscribe"`
+	CanPublishData bool     `json:"can_publish_data"`
+	Sources        []string `json:"sources"`
+	Hidden         bool     `json:"hidden"`
+}
+
+// voicePermissionsFor maps a member role and the room's voice settings to LiveKit permissions.
+// Viewers are always listen-only; only moderators may join hidden.
+func voicePermissionsFor(room *db.Room, role string, hidden bool) roomVoicePermissions {
+	perms := roomVoicePermissions{
+		Role:         role,
+		CanSubscribe: true,
+		Sources:      []string{},
+		Hidden:       hidden && isRoomModerator(role),
+	}
+	if role == roomRoleViewer {
+		return perms
+	}
+
+	perms.CanPublishData = true
+	if roleAtLeast(role, roomVoicePublishRole(room)) {
+		perms.Sources = append(perms.Sources, sourceName(livekit.TrackSource_MICROPHONE), sourceName(livekit.TrackSource_CAMERA))
+	}
+	if roleAtLeast(role, roomScreenShareRole(room)) {
+		perms.Sources = append(perms.Sources, sourceName(livekit.TrackSource_SCREEN_SHARE), sourceName(livekit.TrackSource_SCREEN_SHARE_AUDIO))
+	}
+	perms.CanPublish = len(perms.Sources) > 0
+	return perms
+}
+
+// canPublishMedia reports whether the permissions allow a media flag to be switched on.
+func (p roomVoicePermissions) canPublishMedia(source livekit.TrackSource) bool {
+	name := sourceName(source)
+	for _, allowed := range p.Sources {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// videoGrant converts the permissions into a LiveKit grant for the room.
+func (p roomVoicePermissions) videoGrant(roomID string) *auth.VideoGrant {
+	grant := &auth.VideoGrant{
+		RoomJoin: true,
+		Room:     roomID,
+		Hidden:   p.Hidden,
+	}
+	grant.SetCanPublish(p.CanPublish)
+	grant.SetCanSubscribe(p.CanSubscribe)
+	grant.SetCanPublishData(p.CanPublishData)
+	grant.SetCanUpdateOwnMetadata(false)
+	if p.CanPublish {
+		grant.CanPublishSources = p.Sources
+	}
+	return grant
+}
+
+// participantMetadata is the JSON stored in the LiveKit participant metadata.
+func (p roomVoicePermissions) participantMetadata() string {
+	metadata, _ := json.Marshal(gin.H{"role": p.Role})
+	return string(metadata)
+}
+
+// sourceName is the lowercase track source name LiveKit expects in grants.
+func sourceName(source livekit.TrackSource) string {
+	switch source {
+	case livekit.TrackSource_MICROPHONE:
+		return "microphone"
+	case livekit.TrackSource_CAMERA:
+		return "camera"
+	case livekit.TrackSource_SCREEN_SHARE:
+		return "screen_share"
+	case livekit.TrackSource_SCREEN_SHARE_AUDIO:
+		return "screen_share_audio"
+	}
+	return "unknown"
+}
+
+func roomVoicePublishRole(room *db.Room) string {
+	if room.VoicePublishRole == "" {
+		return roomRoleMember
+	}
+	return room.VoicePublishRole
+}
+
+func roomScreenShareRole(room *db.Room) string {
+	if room.ScreenShareRole == "" {
+		return roomRoleMember
+	}
+	return room.ScreenShareRole
+}
+
+// GetRoomVoiceSettings returns the room's voice settings and the caller's resulting permissions.
+func GetRoomVoiceSettings(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room membership"})
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Room membership is required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"room_id":            room.RoomID,
+		"voice_publish_role": roomVoicePublishRole(room),
+		"screen_share_role":  roomScreenShareRole(room),
+		"permissions":        voicePermissionsFor(room, member.Role, false),
+	})
+}
+
+// UpdateRoomVoiceSettings changes which roles may publish media in the room. Creator or admins only.
+func UpdateRoomVoiceSettings(c *gin.Context) {
+	var req struct {
+		VoicePublishRole string `json:"voice_publish_role" binding:"omitempty,oneof=creator admin member"`
+		ScreenShareRole  string `json:"screen_share_role" binding:"omitempty,oneof=creator admin member"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room membership"})
+		return
+	}
+	if member == nil || !isRoomModerator(member.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permissions"})
+		return
+	}
+	if rejectDirectRoom(c, room) {
+		return
+	}
+
+	if req.VoicePublishRole != "" {
+		room.VoicePublishRole = req.VoicePublishRole
+	}
+	if req.ScreenShareRole != "" {
+		room.ScreenShareRole = req.ScreenShareRole
+	}
+	if err := db.DB.Model(room).Updates(map[string]interface{}{
+		"voice_publish_role": roomVoicePublishRole(room),
+		"screen_share_role":  roomScreenShareRole(room),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room voice settings"})
+		return
+	}
+
+	publishRoomEvent(room.RoomID, roomEventRoomUpdated, gin.H{
+		"voice_publish_role": roomVoicePublishRole(room),
+		"screen_share_role":  roomScreenShareRole(room),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"room_id":            room.RoomID,
+		"voice_publish_role": roomVoicePublishRole(room),
+		"screen_share_role":  roomScreenShareRole(room),
+	})
+}
@@ -4,14 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"GoCall_api/config"
 	"GoCall_api/db"
+	"GoCall_api/metrics"
+	"GoCall_api/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -45,6 +49,7 @@ type roomStateRoom struct {
 	UserID    string `json:"user_id"`
 	Name      string `json:"name"`
 	Type      string `json:"type"`
+	Kind      string `json:"kind"`
 	CreatedAt string `json:"created_at"`
 }
 
@@ -56,11 +61,13 @@ type roomStateResponse struct {
 }
 
 type roomVoiceCredentialsResponse struct {
-	URL      string `json:"url"`
-	Token    string `json:"token"`
-	RoomName string `json:"room_name"`
-	Identity string `json:"identity"`
-	Name     string `json:"name"`
+	URL         string               `json:"url"`
+	Token       string               `json:"token"`
+	RoomName    string               `json:"room_name"`
+	Identity    string               `json:"identity"`
+	Name        string               `json:"name"`
+	ExpiresIn   int                  `json:"expires_in"`
+	Permissions roomVoicePermissions `json:"permissions"`
 }
 
 func resolveRoomByParam(idOrRoomID string) (*db.Room, error) {
@@ -93,11 +100,13 @@ func getAuthenticatedDBUser(c *gin.Context) (*db.User, bool) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		} else {
+			utils.Log(c).Error("failed to fetch authenticated user", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authenticated user"})
 		}
 		return nil, false
 	}
 
+	utils.SetLogUser(c, currentUser.UserID)
 	return &currentUser, true
 }
 
@@ -160,6 +169,14 @@ func JoinRoom(c *gin.Context) {
 		return
 	}
 
+	if banned, err := isRoomBanned(room.RoomID, currentUser.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check room bans"})
+		return
+	} else if banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this room"})
+		return
+	}
+
 	member = db.RoomMember{
 		RoomID: room.RoomID,
 		UserID: currentUser.UserID,
@@ -178,6 +195,8 @@ func JoinRoom(c *gin.Context) {
 		return
 	}
 
+	publishRoomEvent(room.RoomID, roomEventMemberJoined, gin.H{"user_id": member.UserID, "role": member.Role})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Joined room", "room_id": room.RoomID})
 }
 
@@ -194,12 +213,12 @@ func GetRoomState(c *gin.Context) {
 		return
 	}
 
-	member, err := ensureRoomMember(room, currentUser)
+	allowed, err := canViewRoomState(room, currentUser.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
 		return
 	}
-	if member == nil && room.Type != "public" {
+	if !allowed {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not a room member"})
 		return
 	}
@@ -210,6 +229,17 @@ func GetRoomState(c *gin.Context) {
 		return
 	}
 
+	// Скрываем онлайн-статус по настройкам приватности
+	memberIDs := make([]string, len(members))
+	for i, member := range members {
+		memberIDs[i] = member.UserID
+	}
+	hiddenOnline, err := hiddenOnlineStatus(currentUser.UserID, memberIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check privacy settings"})
+		return
+	}
+
 	memberStates := make([]roomMemberState, 0, len(members))
 	for _, member := range members {
 		var user db.User
@@ -222,7 +252,7 @@ func GetRoomState(c *gin.Context) {
 			UserID:   user.UserID,
 			Username: user.Username,
 			Name:     user.Name,
-			IsOnline: user.IsOnline,
+			IsOnline: user.IsOnline && !hiddenOnline[user.UserID],
 			Role:     member.Role,
 			JoinedAt: member.JoinedAt.Format(http.TimeFormat),
 		})
@@ -234,6 +264,16 @@ func GetRoomState(c *gin.Context) {
 		return
 	}
 
+	voiceUserIDs := make([]string, len(voiceParticipants))
+	for i, voiceParticipant := range voiceParticipants {
+		voiceUserIDs[i] = voiceParticipant.UserID
+	}
+	hiddenVoiceOnline, err := hiddenOnlineStatus(currentUser.UserID, voiceUserIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check privacy settings"})
+		return
+	}
+
 	voiceStates := make([]roomVoiceParticipantState, 0, len(voiceParticipants))
 	inVoice := false
 	for _, voiceParticipant := range voiceParticipants {
@@ -251,7 +291,7 @@ func GetRoomState(c *gin.Context) {
 			UserID:          user.UserID,
 			Username:        user.Username,
 			Name:            user.Name,
-			IsOnline:        user.IsOnline,
+			IsOnline:        user.IsOnline && !hiddenVoiceOnline[user.UserID],
 			IsMicEnabled:    voiceParticipant.IsMicEnabled,
 			IsCameraEnabled: voiceParticipant.IsCameraEnabled,
 			IsScreenSharing: voiceParticipant.IsScreenSharing,
@@ -267,6 +307,7 @@ func GetRoomState(c *gin.Context) {
 			UserID:    room.UserID,
 			Name:      room.Name,
 			Type:      room.Type,
+			Kind:      room.Kind,
 			CreatedAt: room.CreatedAt.Format(http.TimeFormat),
 		},
 		Members:           memberStates,
@@ -307,7 +348,7 @@ func JoinRoomVoice(c *gin.Context) {
 	}
 	if err := db.DB.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
-		DoNothing: true,
+		DoUpdates: clause.Assignments(map[string]interface{}{"updated_at": time.Now()}),
 	}).Create(&voiceParticipant).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join room voice"})
 		return
@@ -318,6 +359,8 @@ func JoinRoomVoice(c *gin.Context) {
 		return
 	}
 
+	publishRoomEvent(room.RoomID, roomEventVoiceJoined, voiceEventData(&voiceParticipant))
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":           "Joined room voice",
 		"room_id":           room.RoomID,
@@ -351,9 +394,45 @@ func LeaveRoomVoice(c *gin.Context) {
 		return
 	}
 
+	publishRoomEvent(room.RoomID, roomEventVoiceLeft, gin.H{"user_id": currentUser.UserID, "reason": "left"})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Left room voice", "room_id": room.RoomID})
 }
 
+// HeartbeatRoomVoice refreshes the authenticated user's voice presence so the reaper keeps it.
+func HeartbeatRoomVoice(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	room, err := resolveRoomByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	now := time.Now()
+	result := db.DB.Model(&db.RoomVoiceParticipant{}).
+		Where("room_id = ? AND user_id = ?", room.RoomID, currentUser.UserID).
+		Update("updated_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh room voice presence"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not in room voice"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Room voice presence refreshed",
+		"room_id":    room.RoomID,
+		"updated_at": now,
+		"expires_in": int(config.Current.Voice.PresenceTTL.Seconds()),
+	})
+}
+
 // UpdateRoomVoiceMedia updates the authenticated user's media flags while they are in room voice.
 func UpdateRoomVoiceMedia(c *gin.Context) {
 	currentUser, ok := getAuthenticatedDBUser(c)
@@ -387,6 +466,25 @@ func UpdateRoomVoiceMedia(c *gin.Context) {
 		return
 	}
 
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a room member"})
+		return
+	}
+
+	// Включать можно только разрешённые роли источники
+	perms := voicePermissionsFor(room, member.Role, false)
+	if (req.IsMicEnabled != nil && *req.IsMicEnabled && !perms.canPublishMedia(livekit.TrackSource_MICROPHONE)) ||
+		(req.IsCameraEnabled != nil && *req.IsCameraEnabled && !perms.canPublishMedia(livekit.TrackSource_CAMERA)) ||
+		(req.IsScreenSharing != nil && *req.IsScreenSharing && !perms.canPublishMedia(livekit.TrackSource_SCREEN_SHARE)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your room role cannot publish this media"})
+		return
+	}
+
 	if req.IsMicEnabled != nil {
 		voiceParticipant.IsMicEnabled = *req.IsMicEnabled
 	}
@@ -402,6 +500,8 @@ func UpdateRoomVoiceMedia(c *gin.Context) {
 		return
 	}
 
+	publishRoomEvent(room.RoomID, roomEventMediaChanged, voiceEventData(&voiceParticipant))
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":           "Updated room voice media state",
 		"room_id":           room.RoomID,
@@ -413,6 +513,8 @@ func UpdateRoomVoiceMedia(c *gin.Context) {
 }
 
 // GetRoomVoiceCredentials returns LiveKit credentials for a room-scoped voice participant.
+// Grants follow the member's role and the room's voice settings; moderators may pass
+// ?hidden=true to join without being visible to other participants.
 func GetRoomVoiceCredentials(c *gin.Context) {
 	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
 	c.Header("Pragma", "no-cache")
@@ -438,9 +540,9 @@ func GetRoomVoiceCredentials(c *gin.Context) {
 		return
 	}
 
-	livekitURL := resolveLiveKitPublicURL(c, os.Getenv("LIVEKIT_URL"))
-	livekitAPIKey := os.Getenv("LIVEKIT_API_KEY")
-	livekitAPISecret := os.Getenv("LIVEKIT_API_SECRET")
+	livekitURL := resolveLiveKitPublicURL(c, config.Current.LiveKit.URL)
+	livekitAPIKey := config.Current.LiveKit.APIKey
+	livekitAPISecret := config.Current.LiveKit.APISecret
 	if livekitURL == "" || livekitAPIKey == "" || livekitAPISecret == "" {
 		c.JSON(http.StatusNotImplemented, gin.H{
 			"error": "LiveKit is not configured. Set LIVEKIT_URL, LIVEKIT_API_KEY, and LIVEKIT_API_SECRET.",
@@ -448,31 +550,45 @@ func GetRoomVoiceCredentials(c *gin.Context) {
 		return
 	}
 
-	canPublish := true
-	canSubscribe := true
-	canPublishData := true
+	member, err := ensureRoomMember(room, currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify room membership"})
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a room member"})
+		return
+	}
+
+	hidden, _ := strconv.ParseBool(c.Query("hidden"))
+	perms := voicePermissionsFor(room, member.Role, hidden)
+	ttl := config.Current.Voice.TokenTTL.Duration
+
 	token := auth.NewAccessToken(livekitAPIKey, livekitAPISecret).
 		SetIdentity(currentUser.UserID).
 		SetName(currentUser.Username).
-		AddGrant(&auth.VideoGrant{
-			RoomJoin:       true,
-			Room:           room.RoomID,
-			CanPublish:     &canPublish,
-			CanSubscribe:   &canSubscribe,
-			CanPublishData: &canPublishData,
-		})
+		SetValidFor(ttl).
+		SetMetadata(perms.participantMetadata()).
+		SetAttributes(map[string]string{"role": perms.Role}).
+		AddGrant(perms.videoGrant(room.RoomID))
 
 	jwt, err := token.ToJWT()
 	if err != nil {
+		metrics.LiveKitTokens.WithLabelValues(metrics.ResultError).Inc()
+		utils.Log(c).Error("failed to generate livekit token", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate LiveKit token"})
 		return
 	}
 
+	metrics.LiveKitTokens.WithLabelValues(metrics.ResultSuccess).Inc()
+
 	c.JSON(http.StatusOK, roomVoiceCredentialsResponse{
-		URL:      livekitURL,
-		Token:    jwt,
-		RoomName: room.RoomID,
-		Identity: currentUser.UserID,
-		Name:     currentUser.Username,
+		URL:         livekitURL,
+		Token:       jwt,
+		RoomName:    room.RoomID,
+		Identity:    currentUser.UserID,
+		Name:        currentUser.Username,
+		ExpiresIn:   int(ttl.Seconds()),
+		Permissions: perms,
 	})
 }
@@ -2,12 +2,13 @@ package handlers
 
 import (
 	"GoCall_api/db"
+	"errors"
 	"net/http"
-	"sort"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func getAuthenticatedNumericUserID(c *gin.Context) (uint, bool) {
@@ -38,9 +39,10 @@ func RoomExists(c *gin.Context) {
 }
 
 // GetAllPublicRooms returns all public rooms without requiring auth.
+// Deprecated: use GetRoomDirectory, which paginates and reports counts.
 func GetAllPublicRooms(c *gin.Context) {
 	var rooms []db.Room
-	err := db.DB.Where("type = ?", "public").Find(&rooms).Error
+	err := excludeDirectRooms(db.DB.Where("type = ?", "public")).Find(&rooms).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch public rooms"})
 		return
@@ -72,20 +74,33 @@ func GetMyRooms(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+	response, err := withRoomPeers(rooms, currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch direct room peers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": response})
 }
 
 // CreateRoom creates a new room and adds the creator as a member.
 func CreateRoom(c *gin.Context) {
 	var req struct {
-		Name     string `json:"name" binding:"required,min=3,max=50"`
-		Type     string `json:"type" binding:"required,oneof=public private secret"`
-		Password string `json:"password"`
+		Name        string   `json:"name" binding:"required,min=3,max=50"`
+		Type        string   `json:"type" binding:"required,oneof=public private secret"`
+		Password    string   `json:"password"`
+		Description string   `json:"description" binding:"max=200"`
+		Tags        []string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	tags, err := normalizeRoomTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	uid, ok := getAuthenticatedNumericUserID(c)
 	if !ok {
@@ -99,10 +114,12 @@ func CreateRoom(c *gin.Context) {
 	}
 
 	room := db.Room{
-		UserID:   user.UserID,
-		Name:     req.Name,
-		Type:     req.Type,
-		Password: req.Password,
+		UserID:      user.UserID,
+		Name:        req.Name,
+		Type:        req.Type,
+		Password:    req.Password,
+		Description: req.Description,
+		Tags:        tags,
 	}
 	if err := db.DB.Create(&room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
@@ -161,57 +178,53 @@ func GetOrCreateDirectRoom(c *gin.Context) {
 		return
 	}
 
-	memberIDs := []string{currentUser.UserID, friend.UserID}
-	sort.Strings(memberIDs)
-	roomName := "__direct__:" + strings.Join(memberIDs, ":")
+	directKey := db.DirectRoomKey(currentUser.UserID, friend.UserID)
 
-	var room db.Room
+	room := db.Room{
+		UserID:    currentUser.UserID,
+		Type:      "secret",
+		Kind:      db.RoomKindDirect,
+		DirectKey: &directKey,
+	}
 	if err := db.DB.Transaction(func(tx *gorm.DB) error {
-		room = db.Room{
-			UserID: currentUser.UserID,
-			Name:   roomName,
-			Type:   "secret",
+		// Уникальный direct_key защищает от гонки двух одновременных запросов
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "direct_key"}},
+			DoNothing: true,
+		}).Create(&room)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.Where("direct_key = ?", directKey).First(&room).Error
 		}
 
-		return tx.Where("name = ? AND type = ?", roomName, "secret").
-			Attrs(db.Room{
-				UserID: currentUser.UserID,
-				Name:   roomName,
-				Type:   "secret",
-			}).
-			FirstOrCreate(&room).Error
+		for _, memberID := range []string{currentUser.UserID, friend.UserID} {
+			role := roomRoleMember
+			if memberID == room.UserID {
+				role = roomRoleCreator
+			}
+			if err := tx.Create(&db.RoomMember{
+				RoomID: room.RoomID,
+				UserID: memberID,
+				Role:   role,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch or create direct room"})
 		return
 	}
 
-	for _, memberID := range memberIDs {
-		role := "member"
-		if memberID == room.UserID {
-			role = "creator"
-		}
-
-		member := db.RoomMember{}
-		memberErr := db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, memberID).First(&member).Error
-		if memberErr == nil {
-			continue
-		}
-		if memberErr != gorm.ErrRecordNotFound {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room member"})
-			return
-		}
-
-		if err := db.DB.Create(&db.RoomMember{
-			RoomID: room.RoomID,
-			UserID: memberID,
-			Role:   role,
-		}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add direct room member"})
-			return
-		}
+	hidden, err := hiddenOnlineStatus(currentUser.UserID, []string{friend.UserID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check privacy settings"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"room": room})
+	c.JSON(http.StatusOK, gin.H{"room": room, "peer": newRoomPeer(&friend, !hidden[friend.UserID])})
 }
 
 // GetRoomByID returns room details, enforcing visibility by room type.
@@ -248,16 +261,24 @@ func GetRoomByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"room": room})
+	response, err := withRoomPeers([]db.Room{room}, currentUser.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch direct room peer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room": response[0]})
 }
 
 // UpdateRoom updates mutable room fields for creator or admins.
 func UpdateRoom(c *gin.Context) {
 	roomID := c.Param("id")
 	var req struct {
-		Name     string `json:"name" binding:"required,min=3,max=50"`
-		Type     string `json:"type" binding:"required,oneof=public private secret"`
-		Password string `json:"password"`
+		Name        string    `json:"name" binding:"required,min=3,max=50"`
+		Type        string    `json:"type" binding:"required,oneof=public private secret"`
+		Password    string    `json:"password"`
+		Description *string   `json:"description" binding:"omitempty,max=200"`
+		Tags        *[]string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
@@ -288,15 +309,37 @@ func UpdateRoom(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "No permissions"})
 		return
 	}
+	if rejectDirectRoom(c, &room) {
+		return
+	}
 
 	room.Name = req.Name
 	room.Type = req.Type
 	room.Password = req.Password
+	if req.Description != nil {
+		room.Description = *req.Description
+	}
+	if req.Tags != nil {
+		tags, err := normalizeRoomTags(*req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		room.Tags = tags
+	}
 	if err := db.DB.Save(&room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
 		return
 	}
 
+	pruneRoomSubscribers(&room)
+	publishRoomEvent(room.RoomID, roomEventRoomUpdated, gin.H{
+		"name":        room.Name,
+		"type":        room.Type,
+		"description": room.Description,
+		"tags":        splitRoomTags(room.Tags),
+	})
+
 	c.JSON(http.StatusOK, gin.H{"roomID": room.RoomID, "name": room.Name, "type": room.Type})
 }
 
@@ -328,13 +371,33 @@ func DeleteRoom(c *gin.Context) {
 		return
 	}
 
-	db.DB.Where("room_id = ?", room.RoomID).Delete(&db.RoomMember{})
-	db.DB.Where("room_id = ?", room.RoomID).Delete(&db.RoomInvite{})
-	db.DB.Delete(&room)
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteRoomRecords(tx, &room)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+		return
+	}
+
+	publishRoomEvent(room.RoomID, roomEventRoomDeleted, nil)
+	dropRoomSubscriptions(room.RoomID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Room deleted"})
 }
 
+// deleteRoomRecords removes a room together with its members, invites and their notifications,
+// invite codes, voice presence and bans.
+func deleteRoomRecords(tx *gorm.DB, room *db.Room) error {
+	for _, model := range []interface{}{&db.RoomMember{}, &db.RoomInvite{}, &db.RoomInviteCode{}, &db.RoomVoiceParticipant{}, &db.RoomBan{}} {
+		if err := tx.Where("room_id = ?", room.RoomID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("room_id = ? AND type = ?", room.RoomID, notificationRoomInvite).Delete(&db.Notification{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(room).Error
+}
+
 // MakeRoomAdmin promotes an existing room member to admin.
 func MakeRoomAdmin(c *gin.Context) {
 	var req struct {
@@ -370,6 +433,9 @@ func MakeRoomAdmin(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Only creator can assign admin"})
 		return
 	}
+	if rejectDirectRoom(c, &room) {
+		return
+	}
 
 	var targetMember db.RoomMember
 	if db.DB.Where("room_id = ? AND user_id = ?", room.RoomID, req.UserToAdmin).
@@ -381,19 +447,101 @@ func MakeRoomAdmin(c *gin.Context) {
 	targetMember.Role = "admin"
 	db.DB.Save(&targetMember)
 
+	publishRoomEvent(room.RoomID, roomEventMemberRole, gin.H{"user_id": targetMember.UserID, "role": targetMember.Role})
+	notify(db.Notification{UserID: targetMember.UserID, Type: notificationRoomRole, ActorID: currentUser.UserID, RoomID: room.RoomID, Role: targetMember.Role})
+
 	c.JSON(http.StatusOK, gin.H{"message": "User assigned as admin"})
 }
 
-// InviteUserToRoom creates a pending invitation for a registered user.
+// Reasons createRoomInvite skips an invitee.
+const (
+	inviteSkipMember  = "member"
+	inviteSkipBanned  = "banned"
+	inviteSkipBlocked = "blocked"
+	inviteSkipPending = "pending"
+	inviteSkipPrivacy = "privacy"
+)
+
+// createRoomInvite invites a user to the room and notifies them. It returns a
+// skip reason instead when the user is already a member, banned, blocked, invited
+// or does not accept invites from the inviter.
+func createRoomInvite(room *db.Room, inviterID, inviteeID string, expiresAt *time.Time) (string, error) {
+	var memberCount int64
+	if err := db.DB.Model(&db.RoomMember{}).Where("room_id = ? AND user_id = ?", room.RoomID, inviteeID).Count(&memberCount).Error; err != nil {
+		return "", err
+	}
+	if memberCount > 0 {
+		return inviteSkipMember, nil
+	}
+
+	if banned, err := isRoomBanned(room.RoomID, inviteeID); err != nil {
+		return "", err
+	} else if banned {
+		return inviteSkipBanned, nil
+	}
+
+	if blocked, err := isBlockedBetween(inviterID, inviteeID); err != nil {
+		return "", err
+	} else if blocked {
+		return inviteSkipBlocked, nil
+	}
+
+	privacy, err := loadUserPrivacy(inviteeID)
+	if err != nil {
+		return "", err
+	}
+	if allowed, err := audienceAllows(privacy.RoomInvites, inviterID, inviteeID); err != nil {
+		return "", err
+	} else if !allowed {
+		return inviteSkipPrivacy, nil
+	}
+
+	var pendingCount int64
+	if err := db.DB.Model(&db.RoomInvite{}).
+		Where("room_id = ? AND invited_user_id = ? AND status = 'pending' AND (expires_at IS NULL OR expires_at > ?)", room.RoomID, inviteeID, time.Now()).
+		Count(&pendingCount).Error; err != nil {
+		return "", err
+	}
+	if pendingCount > 0 {
+		return inviteSkipPending, nil
+	}
+
+	invite := db.RoomInvite{
+		RoomID:        room.RoomID,
+		InviterUserID: inviterID,
+		InvitedUserID: inviteeID,
+		Status:        "pending",
+		ExpiresAt:     expiresAt,
+	}
+	if err := db.DB.Create(&invite).Error; err != nil {
+		return "", err
+	}
+	notify(db.Notification{UserID: inviteeID, Type: notificationRoomInvite, ActorID: inviterID, RoomID: room.RoomID, RefID: invite.ID})
+	return "", nil
+}
+
+// InviteUserToRoom creates a pending invitation for a registered user, or for
+// every member of one of the inviter's friend groups when group_id is given.
 func InviteUserToRoom(c *gin.Context) {
 	var req struct {
-		RoomID   string `json:"roomID" binding:"required"`
-		Username string `json:"username" binding:"required"`
+		RoomID    string `json:"roomID" binding:"required"`
+		Username  string `json:"username"`
+		GroupID   uint   `json:"group_id"`
+		ExpiresIn int    `json:"expires_in" binding:"min=0"` // seconds, 0 = never
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Username == "") == (req.GroupID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input. Provide either 'username' or 'group_id'"})
+		return
+	}
+	if time.Duration(req.ExpiresIn)*time.Second > maxRoomInviteExpiry {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in is too long"})
 		return
 	}
+	var expiresAt *time.Time
+	if req.ExpiresIn > 0 {
+		expires := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		expiresAt = &expires
+	}
 
 	uid, ok := getAuthenticatedNumericUserID(c)
 	if !ok {
@@ -422,31 +570,87 @@ func InviteUserToRoom(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to invite"})
 		return
 	}
+	if rejectDirectRoom(c, &room) {
+		return
+	}
 
-	var invitedUser db.User
-	if err := db.DB.Where("username = ?", req.Username).First(&invitedUser).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if req.GroupID != 0 {
+		inviteFriendGroupToRoom(c, &room, &inviter, req.GroupID, expiresAt)
 		return
 	}
 
-	var existingInvite db.RoomInvite
-	if err := db.DB.Where("room_id = ? AND invited_user_id = ? AND status = 'pending'",
-		room.RoomID, invitedUser.UserID).First(&existingInvite).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Invite already pending"})
+	invitedUser, err := findVisibleUser(inviter.UserID, "username = ?", req.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
 		return
 	}
 
-	invite := db.RoomInvite{
-		RoomID:        room.RoomID,
-		InviterUserID: inviter.UserID,
-		InvitedUserID: invitedUser.UserID,
-		Status:        "pending",
+	skipped, err := createRoomInvite(&room, inviter.UserID, invitedUser.UserID, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	switch skipped {
+	case inviteSkipMember:
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a room member"})
+		return
+	case inviteSkipBanned:
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is banned from this room"})
+		return
+	case inviteSkipBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot invite this user"})
+		return
+	case inviteSkipPending:
+		c.JSON(http.StatusConflict, gin.H{"error": "Invite already pending"})
+		return
+	case inviteSkipPrivacy:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user does not accept room invites from you"})
+		return
 	}
-	db.DB.Create(&invite)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Invitation sent"})
 }
 
+// inviteFriendGroupToRoom invites every friend in the group, reporting who was skipped and why.
+func inviteFriendGroupToRoom(c *gin.Context, room *db.Room, inviter *db.User, groupID uint, expiresAt *time.Time) {
+	group, ok := findFriendGroup(c, inviter.UserID, groupID)
+	if !ok {
+		return
+	}
+
+	// Только действующие друзья — на случай рассинхронизации группы
+	var inviteeIDs []string
+	if err := db.DB.Table("friend_group_members gm").
+		Joins("JOIN friends f ON f.friend_id = gm.friend_id AND f.user_id = ?", inviter.UserID).
+		Where("gm.group_id = ?", group.ID).
+		Order("gm.id ASC").
+		Pluck("gm.friend_id", &inviteeIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend group members"})
+		return
+	}
+
+	invited := make([]string, 0, len(inviteeIDs))
+	skipped := make([]gin.H, 0)
+	for _, inviteeID := range inviteeIDs {
+		reason, err := createRoomInvite(room, inviter.UserID, inviteeID, expiresAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite", "invited": invited})
+			return
+		}
+		if reason != "" {
+			skipped = append(skipped, gin.H{"user_id": inviteeID, "reason": reason})
+			continue
+		}
+		invited = append(invited, inviteeID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitations sent", "group_id": group.ID, "invited": invited, "skipped": skipped})
+}
+
 // AcceptRoomInvite accepts a pending invitation and joins the room.
 func AcceptRoomInvite(c *gin.Context) {
 	var req struct {
@@ -479,21 +683,57 @@ func AcceptRoomInvite(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Invite not pending"})
 		return
 	}
+	if roomInviteExpired(&invite, time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired"})
+		return
+	}
 
-	invite.Status = "accepted"
-	db.DB.Save(&invite)
+	var room db.Room
+	if err := db.DB.Where("room_id = ?", invite.RoomID).First(&room).Error; err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Room no longer exists"})
+		return
+	}
+
+	if banned, err := isRoomBanned(invite.RoomID, user.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check room bans"})
+		return
+	} else if banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this room"})
+		return
+	}
 
 	var existingMember db.RoomMember
 	if err := db.DB.Where("room_id = ? AND user_id = ?", invite.RoomID, user.UserID).
-		First(&existingMember).Error; err != nil {
-		member := db.RoomMember{
-			RoomID: invite.RoomID,
-			UserID: user.UserID,
-			Role:   "member",
+		First(&existingMember).Error; err == nil {
+		// Уже участник — приглашение больше не нужно
+		if err := db.DB.Delete(&invite).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove invite"})
+			return
 		}
-		db.DB.Create(&member)
+		resolveNotification(invite.InvitedUserID, notificationRoomInvite, invite.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a room member"})
+		return
 	}
 
+	member := db.RoomMember{
+		RoomID: invite.RoomID,
+		UserID: user.UserID,
+		Role:   "member",
+	}
+	// Статус приглашения и членство сохраняются вместе, иначе приглашение примется без участника
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		invite.Status = "accepted"
+		if err := tx.Save(&invite).Error; err != nil {
+			return err
+		}
+		return tx.Create(&member).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invite"})
+		return
+	}
+	resolveNotification(invite.InvitedUserID, notificationRoomInvite, invite.ID)
+	publishRoomEvent(member.RoomID, roomEventMemberJoined, gin.H{"user_id": member.UserID, "role": member.Role})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Invite accepted"})
 }
 
@@ -532,27 +772,7 @@ func DeclineRoomInvite(c *gin.Context) {
 
 	invite.Status = "declined"
 	db.DB.Save(&invite)
+	resolveNotification(invite.InvitedUserID, notificationRoomInvite, invite.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Invite declined"})
 }
-
-// GetRoomInvites returns pending and accepted invites for the authenticated user.
-func GetRoomInvites(c *gin.Context) {
-	uid, ok := getAuthenticatedNumericUserID(c)
-	if !ok {
-		return
-	}
-
-	var user db.User
-	db.DB.First(&user, uid)
-
-	var invites []db.RoomInvite
-	err := db.DB.Where("invited_user_id = ? AND (status = 'pending' OR status = 'accepted')",
-		user.UserID).Find(&invites).Error
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"invites": invites})
-}
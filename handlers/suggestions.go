@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Suggestion list limits.
+const (
+	suggestionDefaultLimit = 20
+	suggestionMaxLimit     = 50
+)
+
+// friendSuggestion is a user the caller may know, with the signals it was ranked by.
+type friendSuggestion struct {
+	UserID        string `json:"user_id"`
+	Username      string `json:"username"`
+	Name          string `json:"name"`
+	MutualFriends int64  `json:"mutual_friends"`
+	SharedRooms   int64  `json:"shared_rooms"`
+}
+
+// GetMutualFriends lists the friends the caller and the user identified by :uuid have in common.
+func GetMutualFriends(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	other, ok := loadVisibleUser(c, currentUser.UserID, c.Param("uuid"))
+	if !ok {
+		return
+	}
+	if other.UserID == currentUser.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot list mutual friends with yourself"})
+		return
+	}
+
+	blocked, err := isBlockedBetween(currentUser.UserID, other.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check block status"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot list mutual friends with this user"})
+		return
+	}
+	privacy, err := loadUserPrivacy(other.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if privacy.HideMutualFriends {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user does not share mutual friends"})
+		return
+	}
+
+	mutual := make([]userProfile, 0)
+	if err := db.DB.Table("friends mine").
+		Select("users.user_id, users.username, users.name").
+		Joins("JOIN friends theirs ON theirs.friend_id = mine.friend_id AND theirs.user_id = ?", other.UserID).
+		Joins("JOIN users ON users.user_id = mine.friend_id").
+		Where("mine.user_id = ?", currentUser.UserID).
+		Order("users.username ASC").
+		Scan(&mutual).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mutual friends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": other.UserID, "mutual_friends": mutual, "count": len(mutual)})
+}
+
+// GetFriendSuggestions lists "people you may know": users who are not yet friends,
+// ranked by friends in common, then by group rooms shared with the caller.
+// Blocked users, users with a pending request either way, users who opted out
+// via hide_from_suggestions and users the caller may not find are left out. Query: limit.
+func GetFriendSuggestions(c *gin.Context) {
+	limit := suggestionDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, suggestionMaxLimit)
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	me := currentUser.UserID
+
+	// Друзья друзей и соседи по групповым комнатам
+	mutualCounts := db.DB.Table("friends mine").
+		Select("theirs.friend_id AS user_id, COUNT(*) AS cnt").
+		Joins("JOIN friends theirs ON theirs.user_id = mine.friend_id").
+		Where("mine.user_id = ?", me).
+		Group("theirs.friend_id")
+	roomCounts := db.DB.Table("room_members mine").
+		Select("theirs.user_id AS user_id, COUNT(*) AS cnt").
+		Joins("JOIN room_members theirs ON theirs.room_id = mine.room_id").
+		Joins("JOIN rooms ON rooms.room_id = mine.room_id AND rooms.kind <> ?", db.RoomKindDirect).
+		Where("mine.user_id = ?", me).
+		Group("theirs.user_id")
+
+	suggestions := make([]friendSuggestion, 0)
+	if err := db.DB.Table("users").
+		Select("users.user_id, users.username, users.name, COALESCE(mf.cnt, 0) AS mutual_friends, COALESCE(sr.cnt, 0) AS shared_rooms").
+		Joins("LEFT JOIN (?) AS mf ON mf.user_id = users.user_id", mutualCounts).
+		Joins("LEFT JOIN (?) AS sr ON sr.user_id = users.user_id", roomCounts).
+		Where("users.user_id <> ?", me).
+		Where("mf.cnt > 0 OR sr.cnt > 0").
+		Where("users.user_id NOT IN (SELECT friend_id FROM friends WHERE user_id = ?)", me).
+		Where("users.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)", me).
+		Where("users.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = ?)", me).
+		Where("users.user_id NOT IN (SELECT to_user_id FROM friend_requests WHERE from_user_id = ? AND status = 'pending')", me).
+		Where("users.user_id NOT IN (SELECT from_user_id FROM friend_requests WHERE to_user_id = ? AND status = 'pending')", me).
+		Where("users.user_id NOT IN (SELECT user_id FROM user_privacies WHERE hide_from_suggestions = ?)", true).
+		Scopes(discoverableTo(me)).
+		Order("mutual_friends DESC, shared_rooms DESC, users.username ASC").
+		Limit(limit).
+		Scan(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
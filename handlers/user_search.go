@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GoCall_api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search paging limits.
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+)
+
+// Search ranks, best first.
+const (
+	searchRankExact          = 0 // username equals the query
+	searchRankUsernamePrefix = 1
+	searchRankNamePrefix     = 2 // display name or one of its words starts with the query
+	searchRankSubstring      = 3
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike quotes LIKE wildcards so user input only matches literally. Use with ESCAPE '\'.
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// userSearchResult is a search hit annotated with the caller's relationship to that user.
+type userSearchResult struct {
+	ID           uint         `json:"id"`
+	UserID       string       `json:"user_id"`
+	Username     string       `json:"username"`
+	Name         string       `json:"name"`
+	Relationship relationship `json:"relationship"`
+}
+
+type userSearchRow struct {
+	ID         uint
+	UserID     string
+	Username   string
+	Name       string
+	SearchRank int
+	SortName   string
+}
+
+// searchCursor is the keyset position after the last returned user.
+type searchCursor struct {
+	Rank int    `json:"r"`
+	Name string `json:"n"`
+	ID   uint   `json:"id"`
+}
+
+func encodeSearchCursor(cursor searchCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSearchCursor(value string) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SearchUsers finds users by username or display name, case-insensitively: exact
+// username first, then username prefixes, display-name prefixes and substrings.
+// A query containing '@' is an exact e-mail lookup instead, which only finds users
+// who enabled allow_email_lookup. The caller, users who blocked the caller and
+// users whose discoverability excludes the caller are never returned. Query: q, limit, cursor.
+func SearchUsers(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+		return
+	}
+
+	limit := searchDefaultLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, searchMaxLimit)
+	}
+
+	var cursor *searchCursor
+	if value := c.Query("cursor"); value != "" {
+		decoded, err := decodeSearchCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		cursor = decoded
+	}
+
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+
+	lowered := strings.ToLower(query)
+	inner := db.DB.Model(&db.User{}).
+		Where("user_id <> ?", currentUser.UserID).
+		// Пользователи, заблокировавшие текущего, не попадают в поиск
+		Where("user_id NOT IN (?)", db.DB.Model(&db.UserBlock{}).Select("blocker_id").Where("blocked_id = ?", currentUser.UserID)).
+		Scopes(discoverableTo(currentUser.UserID))
+	if strings.Contains(query, "@") {
+		inner = inner.
+			Select("id, user_id, username, COALESCE(name, '') AS name, ? AS search_rank, LOWER(username) AS sort_name", searchRankExact).
+			Where("LOWER(email) = ?", lowered).
+			Where("user_id IN (?)", db.DB.Model(&db.UserPrivacy{}).Select("user_id").Where("allow_email_lookup = ?", true))
+	} else {
+		pattern := escapeLike(lowered)
+		inner = inner.
+			Select(`id, user_id, username, COALESCE(name, '') AS name, LOWER(username) AS sort_name,
+				CASE
+					WHEN LOWER(username) = ? THEN ?
+					WHEN LOWER(username) LIKE ? ESCAPE '\' THEN ?
+					WHEN LOWER(name) LIKE ? ESCAPE '\' OR LOWER(name) LIKE ? ESCAPE '\' THEN ?
+					ELSE ?
+				END AS search_rank`,
+				lowered, searchRankExact,
+				pattern+"%", searchRankUsernamePrefix,
+				pattern+"%", "% "+pattern+"%", searchRankNamePrefix,
+				searchRankSubstring).
+			Where(`LOWER(username) LIKE ? ESCAPE '\' OR LOWER(name) LIKE ? ESCAPE '\'`, "%"+pattern+"%", "%"+pattern+"%")
+	}
+
+	results := db.DB.Table("(?) AS results", inner)
+	if cursor != nil {
+		results = results.Where("search_rank > ? OR (search_rank = ? AND (sort_name > ? OR (sort_name = ? AND id > ?)))",
+			cursor.Rank, cursor.Rank, cursor.Name, cursor.Name, cursor.ID)
+	}
+
+	var rows []userSearchRow
+	if err := results.Order("search_rank ASC, sort_name ASC, id ASC").Limit(limit + 1).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		nextCursor = encodeSearchCursor(searchCursor{Rank: last.SearchRank, Name: last.SortName, ID: last.ID})
+	}
+
+	userIDs := make([]string, len(rows))
+	for i, row := range rows {
+		userIDs[i] = row.UserID
+	}
+	rels, err := relationshipsFor(db.DB, currentUser.UserID, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch relationships"})
+		return
+	}
+
+	users := make([]userSearchResult, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, userSearchResult{
+			ID:           row.ID,
+			UserID:       row.UserID,
+			Username:     row.Username,
+			Name:         row.Name,
+			Relationship: rels[row.UserID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "next_cursor": nextCursor})
+}
@@ -3,10 +3,13 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"GoCall_api/db"
+	"GoCall_api/utils"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -33,44 +36,18 @@ func GetUserID(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"userID": user.UserID})
 }
 
-// SearchUsers returns a limited username search result set.
-func SearchUsers(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
-		return
-	}
-
-	var users []userLookupResponse
-	if err := db.DB.Model(&db.User{}).
-		Select("id, username, name").
-		Where("username LIKE ?", "%"+query+"%").
-		Limit(10).
-		Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+// GetUserByUUID returns a user by their UUID, unless their discoverability hides them from the caller
+func GetUserByUUID(c *gin.Context) {
+	currentUser, ok := getAuthenticatedDBUser(c)
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"users": users})
-}
-
-// GetUserByUUID returns a user by their UUID
-func GetUserByUUID(c *gin.Context) {
-	userID := c.Param("uuid")
-	var user userLookupResponse
-	if err := db.DB.Model(&db.User{}).
-		Select("id, username, name").
-		Where("user_id = ?", userID).
-		First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		}
+	user, ok := loadVisibleUser(c, currentUser.UserID, c.Param("uuid"))
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, gin.H{"user": userLookupResponse{ID: user.ID, Username: user.Username, Name: user.Name}})
 }
 
 // GetUserByToken returns the authenticated user's public profile payload.
@@ -103,3 +80,133 @@ func GetUserByToken(c *gin.Context) {
 		"created_at": user.CreatedAt,
 	})
 }
+
+// DeleteAccount permanently deletes the authenticated user after confirming their password.
+// Their direct rooms are deleted. Group rooms they created pass to the oldest admin
+// (or oldest member); rooms nobody can inherit are deleted.
+func DeleteAccount(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, ok := getAuthenticatedDBUser(c)
+	if !ok {
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	var (
+		transfers    []roomOwnershipChange
+		deletedRooms []string
+		leftRooms    []string
+		voiceRooms   []string
+	)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Личные комнаты не наследуются: без собеседника они теряют смысл
+		var directRooms []db.Room
+		if err := tx.Where("kind = ? AND (user_id = ? OR room_id IN (SELECT room_id FROM room_members WHERE user_id = ?))",
+			db.RoomKindDirect, user.UserID, user.UserID).
+			Find(&directRooms).Error; err != nil {
+			return err
+		}
+		for i := range directRooms {
+			if err := deleteRoomRecords(tx, &directRooms[i]); err != nil {
+				return err
+			}
+			deletedRooms = append(deletedRooms, directRooms[i].RoomID)
+		}
+
+		var ownedRooms []db.Room
+		if err := tx.Where("user_id = ? AND kind <> ?", user.UserID, db.RoomKindDirect).Find(&ownedRooms).Error; err != nil {
+			return err
+		}
+		for i := range ownedRooms {
+			room := &ownedRooms[i]
+			successor, err := roomSuccessor(tx, room.RoomID, user.UserID)
+			if err != nil {
+				return err
+			}
+			if successor == "" {
+				if err := deleteRoomRecords(tx, room); err != nil {
+					return err
+				}
+				deletedRooms = append(deletedRooms, room.RoomID)
+				continue
+			}
+			change, err := transferRoomOwnership(tx, room, successor, false)
+			if err != nil {
+				return err
+			}
+			transfers = append(transfers, change)
+		}
+
+		if err := tx.Model(&db.RoomMember{}).Where("user_id = ?", user.UserID).Pluck("room_id", &leftRooms).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.RoomVoiceParticipant{}).Where("user_id = ?", user.UserID).Pluck("room_id", &voiceRooms).Error; err != nil {
+			return err
+		}
+
+		cleanup := []struct {
+			model interface{}
+			query string
+		}{
+			{&db.RoomMember{}, "user_id = ?"},
+			{&db.RoomVoiceParticipant{}, "user_id = ?"},
+			{&db.RoomBan{}, "user_id = ?"},
+			{&db.RoomInvite{}, "invited_user_id = ? OR inviter_user_id = ?"},
+			{&db.Friend{}, "user_id = ? OR friend_id = ?"},
+			{&db.FriendGroupMember{}, "friend_id = ? OR group_id IN (SELECT id FROM friend_groups WHERE owner_id = ?)"},
+			{&db.FriendGroup{}, "owner_id = ?"},
+			{&db.FriendRequest{}, "from_user_id = ? OR to_user_id = ?"},
+			{&db.UserBlock{}, "blocker_id = ? OR blocked_id = ?"},
+			{&db.UserPrivacy{}, "user_id = ?"},
+			{&db.Notification{}, "user_id = ? OR actor_id = ?"},
+			{&db.Message{}, "sender_id = ? OR receiver_id = ?"},
+		}
+		for _, item := range cleanup {
+			args := make([]interface{}, strings.Count(item.query, "?"))
+			for i := range args {
+				args[i] = user.UserID
+			}
+			if err := tx.Where(item.query, args...).Delete(item.model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		utils.Log(c).Error("failed to delete account", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	for _, roomID := range deletedRooms {
+		publishRoomEvent(roomID, roomEventRoomDeleted, nil)
+		dropRoomSubscriptions(roomID)
+	}
+	for _, roomID := range voiceRooms {
+		publishRoomEvent(roomID, roomEventVoiceLeft, gin.H{"user_id": user.UserID, "reason": "account_deleted"})
+	}
+	for _, roomID := range leftRooms {
+		publishRoomEvent(roomID, roomEventMemberLeft, gin.H{"user_id": user.UserID, "reason": "account_deleted"})
+	}
+	for _, change := range transfers {
+		publishOwnershipChange(change)
+	}
+	disconnectChatUser(user.UserID)
+
+	utils.Log(c).Info("account deleted", "rooms_transferred", len(transfers), "rooms_deleted", len(deletedRooms))
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Account deleted",
+		"rooms_transferred": len(transfers),
+		"rooms_deleted":     len(deletedRooms),
+	})
+}
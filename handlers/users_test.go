@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"GoCall_api/db"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// seedDirectRoom creates a direct room between ownerID and peerID and returns its RoomID.
+func seedDirectRoom(t *testing.T, ownerID, peerID string) string {
+	t.Helper()
+	key := ownerID + ":" + peerID
+	room := db.Room{UserID: ownerID, Type: "private", Kind: db.RoomKindDirect, DirectKey: &key}
+	if err := db.DB.Create(&room).Error; err != nil {
+		t.Fatalf("create direct room: %v", err)
+	}
+	for _, userID := range []string{ownerID, peerID} {
+		if err := db.DB.Create(&db.RoomMember{RoomID: room.RoomID, UserID: userID, Role: roomRoleMember}).Error; err != nil {
+			t.Fatalf("create direct member: %v", err)
+		}
+	}
+	return room.RoomID
+}
+
+func TestDeleteAccountDeletesDirectRooms(t *testing.T) {
+	setupTestDB(t)
+	userID := seedUser(t, testUserID, "alice")
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	db.DB.Model(&db.User{}).Where("id = ?", userID).Update("password_hash", string(hash))
+
+	seedRoom(t)
+	seedMember(t, testUserID, roomRoleCreator, false)
+	seedMember(t, testTargetID, roomRoleMember, false)
+	created := seedDirectRoom(t, testUserID, testTargetID)
+	joined := seedDirectRoom(t, testTargetID, testUserID)
+
+	w := callHandler(DeleteAccount, userID, `{"password":"secret"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	var left int64
+	db.DB.Model(&db.Room{}).Where("room_id IN ?", []string{created, joined}).Count(&left)
+	if left != 0 {
+		t.Fatalf("%d direct rooms left, want 0", left)
+	}
+	var members int64
+	db.DB.Model(&db.RoomMember{}).Where("room_id IN ?", []string{created, joined}).Count(&members)
+	if members != 0 {
+		t.Fatalf("%d direct room members left, want 0", members)
+	}
+
+	var group db.Room
+	if err := db.DB.Where("room_id = ?", testRoomID).First(&group).Error; err != nil {
+		t.Fatalf("fetch group room: %v", err)
+	}
+	if group.UserID != testTargetID {
+		t.Fatalf("group room owner = %q, want %q", group.UserID, testTargetID)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"GoCall_api/db"
+	"GoCall_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartVoiceReaper removes voice participants whose last heartbeat is older than ttl,
+// checking every interval. The returned channel is closed once the reaper has
+// stopped after ctx is cancelled.
+func StartVoiceReaper(ctx context.Context, ttl, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := reapStaleVoicePresence(ttl)
+				if err != nil {
+					utils.Logger.Error("voice reaper failed", "error", err)
+					continue
+				}
+				if removed > 0 {
+					utils.Logger.Info("removed stale voice participants", "count", removed)
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// reapStaleVoicePresence deletes participants not seen within ttl and
+// broadcasts a voice_left event for each of them.
+func reapStaleVoicePresence(ttl time.Duration) (int, error) {
+	cutoff := time.Now().Add(-ttl)
+
+	var stale []db.RoomVoiceParticipant
+	if err := db.DB.Where("updated_at < ?", cutoff).Find(&stale).Error; err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, participant := range stale {
+		// Re-check the timestamp so a heartbeat that raced the scan keeps the row.
+		result := db.DB.Where("id = ? AND updated_at < ?", participant.ID, cutoff).Delete(&db.RoomVoiceParticipant{})
+		if result.Error != nil {
+			return removed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		removed++
+		publishRoomEvent(participant.RoomID, roomEventVoiceLeft, gin.H{
+			"user_id": participant.UserID,
+			"reason":  "expired",
+		})
+	}
+
+	return removed, nil
+}
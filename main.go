@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"log"
+	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"GoCall_api/config"
 	"GoCall_api/db"
 	"GoCall_api/handlers"
+	"GoCall_api/metrics"
+	"GoCall_api/roomservice"
 	"GoCall_api/utils"
 
 	"github.com/gin-contrib/cors"
@@ -16,36 +23,75 @@ import (
 )
 
 func main() {
-	// ENV INIT
-	err := utils.CheckEnvLoaded()
+	// CONFIG INIT
+	// `gocall config print [flags]` shows the effective configuration and exits
+	args := os.Args[1:]
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	cfg, err := config.Load(args)
 	if err != nil {
 		log.Fatal(err)
 	}
+	config.Current = cfg
+
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// --------------------------------
+	// LOGGER INIT
+	if err := utils.InitLogger(cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatal(err)
+	}
+	logger := utils.Logger
 	// --------------------------------
 	// DATABASE INIT
 	// check path data exists
-	dataExists, err := exists("./data")
+	dataDir := filepath.Dir(cfg.Database.Path)
+	dataExists, err := exists(dataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if !dataExists {
-		_ = os.Mkdir("./data", 0700)
+		_ = os.MkdirAll(dataDir, 0700)
+	}
+	db.InitDatabase(cfg.Database.Path)
+	if err := metrics.InstrumentDB(db.DB); err != nil {
+		log.Fatal(err)
+	}
+	// --------------------------------
+	// LIVEKIT ROOM SERVICE INIT
+	// voice moderation endpoints answer 503 until LiveKit is configured
+	if cfg.LiveKitConfigured() && cfg.LiveKit.URL != "" {
+		handlers.RoomService = roomservice.New(cfg.LiveKit.URL, cfg.LiveKit.APIKey, cfg.LiveKit.APISecret)
 	}
-	db.InitDatabase("./data/gocall.db")
 	// --------------------------------
 	// VALIDATOR INIT
 	handlers.InitValidator()
 	// --------------------------------
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), utils.RequestID(), utils.RequestLogger(), metrics.Middleware())
 
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     strings.Split(os.Getenv("ALLOW_ORIGINS"), ","), // Allow sources. By default: http://127.0.0.1:1420 http://localhost:1420
+		AllowOrigins:     cfg.CORS.AllowOrigins, // Allow sources. By default: http://127.0.0.1:1420 http://localhost:1420
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Client-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Client-Type", utils.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", utils.RequestIDHeader},
 		AllowCredentials: true,
 	}))
 
+	// Prometheus scrape endpoint
+	router.GET("/metrics", metrics.Handler())
+
+	// Orchestrator probes
+	router.GET("/healthz", handlers.Healthz)
+	router.GET("/readyz", handlers.Readyz)
+
 	publicAPI := router.Group("/api")
 	{
 		// Public routes
@@ -59,16 +105,20 @@ func main() {
 
 		// Public route to list all public rooms
 		publicAPI.GET("/rooms/public", handlers.GetAllPublicRooms)
+		publicAPI.GET("/rooms/directory", handlers.GetRoomDirectory)
 
 		// Public route to get room info if it's public
 		publicAPI.GET("/rooms/:id", handlers.GetRoomByID)
 
 		// Public route to ping-pong
-		publicAPI.GET("/ping", utils.PingPong)
+		publicAPI.GET("/ping", handlers.PingPong)
 
 		// Chat
 		publicAPI.GET("/chat/ws", handlers.HandleChatWebSocket)
 
+		// LiveKit webhooks (authenticated by LiveKit's signature)
+		publicAPI.POST("/livekit/webhook", handlers.HandleLiveKitWebhook)
+
 		// With auth
 		protected := publicAPI.Group("/")
 		protected.Use(utils.JWTMiddleware())
@@ -78,6 +128,9 @@ func main() {
 			protected.GET("/friends/search", handlers.SearchUsers)
 			protected.GET("/user/:uuid", handlers.GetUserByUUID)
 			protected.GET("/user/me", handlers.GetUserByToken)
+			protected.DELETE("/user/me", handlers.DeleteAccount)
+			protected.GET("/user/me/privacy", handlers.GetPrivacySettings)
+			protected.PUT("/user/me/privacy", handlers.UpdatePrivacySettings)
 
 			// Friends
 			protected.GET("/friends", handlers.GetFriends)
@@ -87,9 +140,25 @@ func main() {
 			protected.POST("/friends/accept", handlers.AcceptFriendRequest)
 			protected.POST("/friends/decline", handlers.DeclineFriendRequest)
 			protected.GET("/friends/requests", handlers.GetFriendRequests)
+			protected.GET("/friends/requests/outgoing", handlers.GetOutgoingFriendRequests)
+			protected.POST("/friends/cancel", handlers.CancelFriendRequest)
 			protected.POST("/friends/pin", handlers.PinFriend)
 			protected.DELETE("/friends/unpin", handlers.UnpinFriend)
 			protected.GET("/friends/pinned", handlers.GetPinnedFriends)
+			protected.PUT("/friends/pinned/order", handlers.ReorderPinnedFriends)
+			protected.POST("/friends/block", handlers.BlockUser)
+			protected.DELETE("/friends/unblock", handlers.UnblockUser)
+			protected.GET("/friends/blocked", handlers.GetBlockedUsers)
+			protected.GET("/friends/status/:uuid", handlers.GetRelationship)
+			protected.GET("/friends/mutual/:uuid", handlers.GetMutualFriends)
+			protected.GET("/friends/suggestions", handlers.GetFriendSuggestions)
+			protected.GET("/friends/groups", handlers.GetFriendGroups)
+			protected.POST("/friends/groups", handlers.CreateFriendGroup)
+			protected.PUT("/friends/groups/order", handlers.ReorderFriendGroups)
+			protected.PUT("/friends/groups/:id", handlers.RenameFriendGroup)
+			protected.DELETE("/friends/groups/:id", handlers.DeleteFriendGroup)
+			protected.POST("/friends/groups/:id/members", handlers.AddFriendGroupMembers)
+			protected.DELETE("/friends/groups/:id/members/:uuid", handlers.RemoveFriendGroupMember)
 
 			// Rooms
 			protected.GET("/rooms/mine", handlers.GetMyRooms)
@@ -99,26 +168,110 @@ func main() {
 			protected.GET("/rooms/:id/state", handlers.GetRoomState)
 			protected.POST("/rooms/:id/voice/join", handlers.JoinRoomVoice)
 			protected.POST("/rooms/:id/voice/leave", handlers.LeaveRoomVoice)
+			protected.POST("/rooms/:id/voice/heartbeat", handlers.HeartbeatRoomVoice)
 			protected.POST("/rooms/:id/voice/credentials", handlers.GetRoomVoiceCredentials)
 			protected.PUT("/rooms/:id/voice/media", handlers.UpdateRoomVoiceMedia)
+			protected.GET("/rooms/:id/voice/settings", handlers.GetRoomVoiceSettings)
+			protected.PUT("/rooms/:id/voice/settings", handlers.UpdateRoomVoiceSettings)
+			protected.POST("/rooms/:id/voice/mute", handlers.MuteRoomVoiceParticipant)
+			protected.POST("/rooms/:id/voice/kick", handlers.KickRoomVoiceParticipant)
+			protected.POST("/rooms/:id/voice/end", handlers.EndRoomVoice)
 			protected.PUT("/rooms/:id", handlers.UpdateRoom)
 			protected.DELETE("/rooms/:id", handlers.DeleteRoom)
 			protected.POST("/rooms/:id/make-admin", handlers.MakeRoomAdmin)
+			protected.POST("/rooms/:id/demote", handlers.DemoteRoomMember)
+			protected.POST("/rooms/:id/transfer", handlers.TransferRoomOwnership)
+			protected.POST("/rooms/:id/leave", handlers.LeaveRoom)
+			protected.POST("/rooms/:id/kick", handlers.KickRoomMember)
+			protected.POST("/rooms/:id/ban", handlers.BanRoomMember)
+			protected.POST("/rooms/:id/unban", handlers.UnbanRoomMember)
+			protected.GET("/rooms/:id/bans", handlers.GetRoomBans)
 
 			// Room invites
 			protected.POST("/rooms/invite", handlers.InviteUserToRoom)
 			protected.POST("/rooms/invite/accept", handlers.AcceptRoomInvite)
 			protected.POST("/rooms/invite/decline", handlers.DeclineRoomInvite)
 			protected.GET("/rooms/invites", handlers.GetRoomInvites)
+			protected.POST("/rooms/join-by-code", handlers.JoinRoomByCode)
+			protected.POST("/rooms/:id/invite-codes", handlers.CreateRoomInviteCode)
+			protected.GET("/rooms/:id/invite-codes", handlers.GetRoomInviteCodes)
+			protected.DELETE("/rooms/:id/invite-codes/:code", handlers.RevokeRoomInviteCode)
+			protected.GET("/rooms/:id/invites", handlers.GetRoomSentInvites)
+			protected.DELETE("/rooms/:id/invites/:inviteId", handlers.CancelRoomInvite)
 
 			// Chat
 			protected.GET("/chat/history", handlers.GetChatHistory)
 			protected.GET("/chat/conversations", handlers.GetChatConversations)
 			// protected.GET("/chat/ws", handlers.HandleChatWebSocket)
+
+			// Notifications
+			protected.GET("/notifications", handlers.GetNotifications)
+			protected.POST("/notifications/read", handlers.MarkNotificationsRead)
+
+			// Server administration
+			admin := protected.Group("/admin")
+			admin.Use(utils.AdminOnly())
+			{
+				admin.GET("/status", handlers.GetAdminStatus)
+			}
 		}
 	}
 
-	router.Run(":8080")
+	// --------------------------------
+	// BACKGROUND JOBS
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	voiceReaperDone := handlers.StartVoiceReaper(jobsCtx, cfg.Voice.PresenceTTL.Duration, cfg.Voice.ReapInterval.Duration)
+	friendReaperDone := handlers.StartFriendRequestReaper(jobsCtx, cfg.Friends.RequestTTL.Duration, cfg.Friends.RequestCooldown.Duration, cfg.Friends.ExpireInterval.Duration)
+
+	// --------------------------------
+	// SERVER
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+	}
+
+	go func() {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			logger.Info("listening", "addr", cfg.Server.Addr, "tls", true)
+			err = srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			logger.Info("listening", "addr", cfg.Server.Addr, "tls", false)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// --------------------------------
+	// SHUTDOWN
+	// stop accepting connections, say goodbye to sockets, drain requests, close the DB
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("http server shutdown failed", "error", err)
+	}
+	stopJobs()
+	<-voiceReaperDone
+	<-friendReaperDone
+	handlers.CloseChatClients(shutdownCtx)
+	if err := db.Close(); err != nil {
+		logger.Error("database close failed", "error", err)
+	}
+	logger.Info("server stopped")
 }
 
 func exists(path string) (bool, error) {
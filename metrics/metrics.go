@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"gorm.io/gorm"
+)
+
+const namespace = "gocall"
+
+// Chat message outcomes recorded by ChatMessages.
+const (
+	ChatRelayed        = "relayed"
+	ChatStoredOffline  = "stored_offline"
+	ChatBlockedFriends = "blocked_not_friends"
+	ChatBlockedUser    = "blocked_user"
+)
+
+// Token issuance outcomes recorded by LiveKitTokens.
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// HTTPRequestDuration observes request latency per route, method and status.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// ChatConnections counts open chat WebSockets.
+	ChatConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chat_connections",
+		Help:      "Number of open chat WebSocket connections.",
+	})
+
+	// ChatMessages counts chat frames by outcome.
+	ChatMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chat_messages_total",
+		Help:      "Chat messages by outcome: relayed, stored_offline, blocked_not_friends, blocked_user.",
+	}, []string{"result"})
+
+	// DBQueryDuration observes GORM statement latency per operation.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database statement latency by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	// LiveKitTokens counts LiveKit access tokens issued.
+	LiveKitTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "livekit_tokens_issued_total",
+		Help:      "LiveKit access tokens issued by result.",
+	}, []string{"result"})
+)
+
+// Handler serves the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records HTTPRequestDuration for every request.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.
+			WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+const startKey = "metrics:start"
+
+// InstrumentDB registers GORM callbacks that feed DBQueryDuration and a gauge
+// reporting the current number of room voice participants.
+func InstrumentDB(gdb *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if start, ok := tx.InstanceGet(startKey); ok {
+				DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start.(time.Time)).Seconds())
+			}
+		}
+	}
+
+	cb := gdb.Callback()
+	if err := errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	); err != nil {
+		return err
+	}
+
+	return prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "voice_participants",
+		Help:      "Number of users currently present in room voice.",
+	}, func() float64 {
+		var count int64
+		if err := gdb.Table("room_voice_participants").Count(&count).Error; err != nil {
+			return 0
+		}
+		return float64(count)
+	}))
+}
+
+// GaugeValue reads a gauge's current value, e.g. to report it outside /metrics.
+func GaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetGauge().GetValue()
+}
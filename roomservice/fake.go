@@ -0,0 +1,55 @@
+package roomservice
+
+import (
+	"context"
+	"sync"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// Call is one request recorded by Fake.
+type Call struct {
+	Method   string
+	Room     string
+	Identity string
+	Sources  []livekit.TrackSource
+}
+
+// Fake is an in-memory Client for tests. It records every call and
+// returns Err, if set, instead of succeeding.
+type Fake struct {
+	mu    sync.Mutex
+	Calls []Call
+	Err   error
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) MuteSources(_ context.Context, room, identity string, sources ...livekit.TrackSource) error {
+	return f.record(Call{Method: "MuteSources", Room: room, Identity: identity, Sources: sources})
+}
+
+func (f *Fake) RemoveParticipant(_ context.Context, room, identity string) error {
+	return f.record(Call{Method: "RemoveParticipant", Room: room, Identity: identity})
+}
+
+func (f *Fake) DeleteRoom(_ context.Context, room string) error {
+	return f.record(Call{Method: "DeleteRoom", Room: room})
+}
+
+// Recorded returns a copy of the calls made so far.
+func (f *Fake) Recorded() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.Calls...)
+}
+
+func (f *Fake) record(call Call) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, call)
+	return f.Err
+}
@@ -0,0 +1,163 @@
+package roomservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// Client is the part of the LiveKit RoomService API used for voice moderation.
+// Rooms are named after Room.RoomID and participants after User.UserID.
+type Client interface {
+	// MuteSources server-mutes every published track of the given sources and
+	// revokes the participant's permission to publish them again.
+	MuteSources(ctx context.Context, room, identity string, sources ...livekit.TrackSource) error
+	// RemoveParticipant disconnects a participant from the room.
+	RemoveParticipant(ctx context.Context, room, identity string) error
+	// DeleteRoom disconnects everyone and closes the room.
+	DeleteRoom(ctx context.Context, room string) error
+}
+
+// adminTokenTTL bounds the lifetime of the token signed for each API call.
+const adminTokenTTL = time.Minute
+
+type liveKitClient struct {
+	apiKey    string
+	apiSecret string
+	service   livekit.RoomService
+}
+
+// New returns a Client talking to the LiveKit server at url (ws:// and wss:// are accepted).
+// Participants or rooms LiveKit no longer knows about are not treated as errors:
+// there is nothing left to moderate.
+func New(url, apiKey, apiSecret string) Client {
+	url = strings.Replace(url, "ws://", "http://", 1)
+	url = strings.Replace(url, "wss://", "https://", 1)
+	return &liveKitClient{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		service:   livekit.NewRoomServiceProtobufClient(url, &http.Client{Timeout: 10 * time.Second}),
+	}
+}
+
+func (c *liveKitClient) MuteSources(ctx context.Context, room, identity string, sources ...livekit.TrackSource) error {
+	ctx, err := c.authorize(ctx, room)
+	if err != nil {
+		return err
+	}
+
+	participant, err := c.service.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	if err != nil {
+		return ignoreNotFound(err)
+	}
+
+	var errs []error
+	for _, track := range participant.GetTracks() {
+		if track.GetMuted() || !containsSource(sources, track.GetSource()) {
+			continue
+		}
+		_, err := c.service.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     room,
+			Identity: identity,
+			TrackSid: track.GetSid(),
+			Muted:    true,
+		})
+		errs = append(errs, ignoreNotFound(err))
+	}
+
+	// Без этого участник может сразу включить микрофон обратно
+	if permission := participant.GetPermission(); permission != nil {
+		revokeSources(permission, sources)
+		_, err := c.service.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+			Room:       room,
+			Identity:   identity,
+			Permission: permission,
+		})
+		errs = append(errs, ignoreNotFound(err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *liveKitClient) RemoveParticipant(ctx context.Context, room, identity string) error {
+	ctx, err := c.authorize(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = c.service.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	return ignoreNotFound(err)
+}
+
+func (c *liveKitClient) DeleteRoom(ctx context.Context, room string) error {
+	ctx, err := c.authorize(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = c.service.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: room})
+	return ignoreNotFound(err)
+}
+
+// authorize attaches a short-lived room admin token to the request context.
+func (c *liveKitClient) authorize(ctx context.Context, room string) (context.Context, error) {
+	token, err := auth.NewAccessToken(c.apiKey, c.apiSecret).
+		SetValidFor(adminTokenTTL).
+		AddGrant(&auth.VideoGrant{RoomAdmin: true, RoomCreate: true, Room: room}).
+		ToJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	return twirp.WithHTTPRequestHeaders(ctx, header)
+}
+
+func ignoreNotFound(err error) error {
+	var twerr twirp.Error
+	if errors.As(err, &twerr) && twerr.Code() == twirp.NotFound {
+		return nil
+	}
+	return err
+}
+
+// publishableSources is what an empty CanPublishSources allows.
+var publishableSources = []livekit.TrackSource{
+	livekit.TrackSource_CAMERA,
+	livekit.TrackSource_MICROPHONE,
+	livekit.TrackSource_SCREEN_SHARE,
+	livekit.TrackSource_SCREEN_SHARE_AUDIO,
+}
+
+// revokeSources removes sources from the permission's publishable sources.
+// When nothing is left, publishing is revoked altogether, since an empty list means "any source".
+func revokeSources(permission *livekit.ParticipantPermission, sources []livekit.TrackSource) {
+	allowed := permission.GetCanPublishSources()
+	if len(allowed) == 0 {
+		allowed = publishableSources
+	}
+
+	remaining := make([]livekit.TrackSource, 0, len(allowed))
+	for _, source := range allowed {
+		if !containsSource(sources, source) {
+			remaining = append(remaining, source)
+		}
+	}
+	permission.CanPublishSources = remaining
+	if len(remaining) == 0 {
+		permission.CanPublish = false
+	}
+}
+
+func containsSource(sources []livekit.TrackSource, source livekit.TrackSource) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package roomservice
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestRevokeSources(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []livekit.TrackSource
+		revoke      []livekit.TrackSource
+		wantSources []livekit.TrackSource
+		wantPublish bool
+	}{
+		{
+			name:        "any source",
+			revoke:      []livekit.TrackSource{livekit.TrackSource_MICROPHONE},
+			wantSources: []livekit.TrackSource{livekit.TrackSource_CAMERA, livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO},
+			wantPublish: true,
+		},
+		{
+			name:        "restricted sources",
+			allowed:     []livekit.TrackSource{livekit.TrackSource_MICROPHONE, livekit.TrackSource_CAMERA},
+			revoke:      []livekit.TrackSource{livekit.TrackSource_CAMERA},
+			wantSources: []livekit.TrackSource{livekit.TrackSource_MICROPHONE},
+			wantPublish: true,
+		},
+		{
+			name:        "last source",
+			allowed:     []livekit.TrackSource{livekit.TrackSource_MICROPHONE},
+			revoke:      []livekit.TrackSource{livekit.TrackSource_MICROPHONE, livekit.TrackSource_CAMERA},
+			wantSources: []livekit.TrackSource{},
+			wantPublish: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permission := &livekit.ParticipantPermission{CanPublish: true, CanSubscribe: true, CanPublishSources: tt.allowed}
+			revokeSources(permission, tt.revoke)
+			if !reflect.DeepEqual(permission.CanPublishSources, tt.wantSources) {
+				t.Fatalf("sources = %v, want %v", permission.CanPublishSources, tt.wantSources)
+			}
+			if permission.CanPublish != tt.wantPublish || !permission.CanSubscribe {
+				t.Fatalf("can_publish = %v, can_subscribe = %v, want %v, true", permission.CanPublish, permission.CanSubscribe, tt.wantPublish)
+			}
+		})
+	}
+}
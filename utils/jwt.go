@@ -2,21 +2,23 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
+	"GoCall_api/config"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 func signingKey() []byte {
-	return []byte(os.Getenv(SECRET_KEY))
+	return []byte(config.Current.Auth.SecretKey)
 }
 
-// GenerateJWT creates a signed JWT for the given numeric user ID.
-func GenerateJWT(userID int) (string, error) {
+// GenerateJWT creates a signed JWT for the given numeric user ID and user UUID.
+func GenerateJWT(userID int, userUUID string) (string, error) {
 	claims := &jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"user_id":   userID,
+		"user_uuid": userUUID,
+		"exp":       time.Now().Add(config.Current.Auth.WebTokenTTL.Duration).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
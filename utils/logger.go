@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request correlation ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestIDKey  = "request_id"
+	logUserKey    = "log_user_id"
+	logRoomKey    = "log_room_id"
+	maxRequestID  = 128
+	roomRoutePart = "/rooms/:id"
+)
+
+// Logger is the application-wide structured logger.
+var Logger = slog.Default()
+
+// InitLogger configures Logger and the standard library logger.
+// level is one of debug, info, warn, error; format is text or json.
+func InitLogger(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	Logger = slog.New(handler)
+	slog.SetDefault(Logger)
+	return nil
+}
+
+// RequestID reuses a well-formed incoming X-Request-ID or generates one,
+// and echoes it back in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger writes one structured access log line per request.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		Log(c).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// SetLogUser attaches the authenticated user's UUID to the request's log fields.
+func SetLogUser(c *gin.Context, userID string) {
+	c.Set(logUserKey, userID)
+}
+
+// SetLogRoom attaches a room UUID to the request's log fields.
+func SetLogRoom(c *gin.Context, roomID string) {
+	c.Set(logRoomKey, roomID)
+}
+
+// Log returns a logger carrying the request ID, user and room of the request.
+func Log(c *gin.Context) *slog.Logger {
+	logger := Logger
+	if requestID := c.GetString(requestIDKey); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if userID := c.GetString(logUserKey); userID != "" {
+		logger = logger.With("user_id", userID)
+	}
+
+	roomID := c.GetString(logRoomKey)
+	if roomID == "" && strings.Contains(c.FullPath(), roomRoutePart) {
+		roomID = c.Param("id")
+	}
+	if roomID != "" {
+		logger = logger.With("room_id", roomID)
+	}
+
+	return logger
+}
@@ -5,6 +5,9 @@ import (
 	"strings"
 	"time"
 
+	"GoCall_api/config"
+	"GoCall_api/db"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -60,6 +63,36 @@ func JWTMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", uint(userID))
 
+		// Токены, выданные до появления user_uuid, его не содержат
+		if userUUID, ok := claims["user_uuid"].(string); ok && userUUID != "" {
+			c.Set("user_uuid", userUUID)
+			SetLogUser(c, userUUID)
+		}
+
+		c.Next()
+	}
+}
+
+// AdminOnly restricts a route to server administrators listed in the config.
+// It must run after JWTMiddleware. Tokens without a user_uuid claim are resolved
+// from the database.
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userUUID := c.GetString("user_uuid")
+		if userUUID == "" {
+			if err := db.DB.Model(&db.User{}).Where("id = ?", c.GetUint("user_id")).Pluck("user_id", &userUUID).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authenticated user"})
+				c.Abort()
+				return
+			}
+			SetLogUser(c, userUUID)
+		}
+		if !config.Current.Auth.IsAdmin(userUUID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -82,9 +115,9 @@ func RefreshToken(c *gin.Context) {
 	isDesktopClient := c.Request.Header.Get("X-Client-Type") == "desktop"
 
 	// Set expires life of token
-	expiration := time.Hour * 24 // 24 hours for web
+	expiration := config.Current.Auth.WebTokenTTL.Duration
 	if isDesktopClient {
-		expiration *= 30 // 30 days for desktop
+		expiration = config.Current.Auth.DesktopTokenTTL.Duration
 	}
 
 	// create new token
@@ -112,8 +145,3 @@ func ValidateToken(c *gin.Context) {
 		return
 	}
 }
-
-// PingPong returns a lightweight healthcheck response.
-func PingPong(c *gin.Context) { // todo move it to some other file
-	c.JSON(http.StatusOK, gin.H{"message": "pong"})
-}